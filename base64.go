@@ -0,0 +1,115 @@
+package sh
+
+import (
+	"encoding/base64"
+	"io"
+)
+
+// Base64Encode returns an Executable that encodes its stdin as base64 and
+// writes the result to its stdout, like base64 -w 0.  By default the standard
+// alphabet is used, with padding, and the output is one long line with no
+// newline at the end; see Base64Wrap.  Data is encoded as it streams through,
+// and no program is run, so it works the same everywhere, unlike the flags of
+// base64 binaries.
+func Base64Encode(opts ...Base64Option) Executable {
+	o := base64Options{enc: base64.StdEncoding}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		ww := &wrapWriter{w: w, width: o.wrap}
+		enc := base64.NewEncoder(o.enc, ww)
+		if _, err := io.Copy(enc, r); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		return ww.finish()
+	})
+}
+
+// Base64Decode returns an Executable that decodes the base64 of its stdin and
+// writes the result to its stdout, like base64 -d.  Newlines in the input are
+// ignored, so wrapped input can be decoded.  Anything else that is not base64
+// in the alphabet chosen is an error.  Like Base64Encode, it streams and runs
+// no program.  Base64Wrap has no effect on it.
+func Base64Decode(opts ...Base64Option) Executable {
+	o := base64Options{enc: base64.StdEncoding}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		_, err := io.Copy(w, base64.NewDecoder(o.enc, r))
+		return err
+	})
+}
+
+// Base64Option configures Base64Encode and Base64Decode.
+type Base64Option func(*base64Options)
+
+type base64Options struct {
+	enc  *base64.Encoding
+	wrap int
+}
+
+// Base64URL makes Base64Encode and Base64Decode use the URL and file name
+// safe alphabet, which has - and _ in place of + and /, as
+// base64.URLEncoding does.
+func Base64URL() Base64Option {
+	return func(o *base64Options) {
+		o.enc = base64.URLEncoding
+	}
+}
+
+// Base64Wrap makes Base64Encode break its output into lines of n characters,
+// each ending with a newline, including the last line, which may be shorter,
+// as base64 and PEM files do with n of 76 and 64.  An n of 0 or less does not
+// wrap.
+func Base64Wrap(n int) Base64Option {
+	return func(o *base64Options) {
+		o.wrap = n
+	}
+}
+
+// wrapWriter is an io.Writer that writes to w with a newline after every
+// width bytes, if width is more than 0.
+type wrapWriter struct {
+	w     io.Writer
+	width int
+	// col is the number of bytes written since the last newline.
+	col int
+}
+
+func (ww *wrapWriter) Write(p []byte) (int, error) {
+	if ww.width <= 0 {
+		return ww.w.Write(p)
+	}
+	n := 0
+	for len(p) > 0 {
+		k := min(ww.width-ww.col, len(p))
+		m, err := ww.w.Write(p[:k])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		ww.col += k
+		p = p[k:]
+		if ww.col == ww.width {
+			if _, err := io.WriteString(ww.w, "\n"); err != nil {
+				return n, err
+			}
+			ww.col = 0
+		}
+	}
+	return n, nil
+}
+
+// finish ends the last line written with a newline, if it has none.
+func (ww *wrapWriter) finish() error {
+	if ww.col == 0 {
+		return nil
+	}
+	_, err := io.WriteString(ww.w, "\n")
+	return err
+}
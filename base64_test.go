@@ -0,0 +1,58 @@
+package sh_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleBase64Encode() {
+	fmt.Println(sh.PipeWith("Hi there!", sh.Base64Encode()))
+	fmt.Println(sh.PipeWith("\xfb\xff\xbf", sh.Base64Encode()))
+	fmt.Println(sh.PipeWith("\xfb\xff\xbf", sh.Base64Encode(sh.Base64URL())))
+	// output:
+	// SGkgdGhlcmUh
+	// +/+/
+	// -_-_
+}
+
+func ExampleBase64Decode() {
+	fmt.Println(sh.PipeWith("SGkgdGhl\ncmUh\n", sh.Base64Decode()))
+	// output:
+	// Hi there!
+}
+
+func TestBase64Wrap(t *testing.T) {
+	in := strings.Repeat("\xff\xfe\xfd", 30)
+	out, err := sh.PipeWith(in, sh.Base64Encode(sh.Base64Wrap(76))).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.StdEncoding.EncodeToString([]byte(in))
+	if want := enc[:76] + "\n" + enc[76:] + "\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	back, err := sh.PipeWith(out, sh.Base64Decode()).Run()
+	if err != nil || back != in {
+		t.Errorf("decoding wrapped output got %q, %v, want the input back", back, err)
+	}
+
+	url, err := sh.PipeWith(in, sh.Base64Encode(sh.Base64URL(), sh.Base64Wrap(4))).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(url, "+/") || !strings.HasPrefix(url, "__79\n") {
+		t.Errorf("got %q, want the URL alphabet wrapped at 4", url)
+	}
+	back, err = sh.PipeWith(url, sh.Base64Decode(sh.Base64URL())).Run()
+	if err != nil || back != in {
+		t.Errorf("decoding URL output got %q, %v, want the input back", back, err)
+	}
+	if _, err := sh.PipeWith("not base64!", sh.Base64Decode()).Run(); err == nil {
+		t.Error("no error for invalid input")
+	}
+}
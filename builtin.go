@@ -0,0 +1,347 @@
+package sh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"labix.org/v2/pipe"
+)
+
+// Func returns an Executable that runs fn as a stage of a pipe, with r reading
+// the stage's stdin and w writing its stdout.  This lets arbitrary Go code sit
+// between real commands.  An error returned by fn is the Executable's error.
+//
+// If the pipe is killed, for example by a context passed to RunContext, reads
+// from r and writes to w fail from then on, so fn should give up on the first
+// error it sees.  Likewise, writes to w fail once the next stage of a Pipe
+// stops reading, as Head does; fn should stop then too, and the error from
+// that is not the Executable's error.
+func Func(fn func(r io.Reader, w io.Writer) error) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTask(s, func(ctx context.Context, s *pipe.State) error {
+			return pipeError(fn(ctxReader{ctx, stdin(s)}, ctxWriter{ctx, s.Stdout}))
+		})
+	}}
+}
+
+// MapLines returns an Executable that calls fn on each line of its stdin and
+// writes the result to its stdout.  The line passed to fn does not include its
+// newline, which is added back after fn returns.  A final line with no newline
+// is written without one.  Lines may be of any length.
+func MapLines(fn func(line string) string) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		return forEachLine(r, func(line string, eol bool) error {
+			return writeLine(w, fn(line), eol)
+		})
+	})
+}
+
+// MapLines0 works like MapLines, but for records that end with a NUL byte
+// rather than a newline, such as file names written by find -print0, which may
+// hold newlines.
+func MapLines0(fn func(record string) string) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		return forEachRecord(r, 0, func(rec string, end bool) error {
+			return writeRecord(w, fn(rec), 0, end)
+		})
+	})
+}
+
+// Prefix returns an Executable that copies its stdin to its stdout with tag
+// put at the start of every line, including a final line with no newline.
+// This tells apart the output of several Executables writing to the same
+// place at once.
+func Prefix(tag string) Executable {
+	return MapLines(func(line string) string {
+		return tag + line
+	})
+}
+
+// Echo returns an Executable that writes its arguments to its stdout,
+// separated by spaces and followed by a newline, like echo.  Unlike
+// Cmd("echo"), it runs no program, so it works the same everywhere, even
+// where there is no echo to run.
+func Echo(args ...string) Executable {
+	line := strings.Join(args, " ") + "\n"
+	return Func(func(r io.Reader, w io.Writer) error {
+		_, err := io.WriteString(w, line)
+		return err
+	})
+}
+
+// Cat returns an Executable that writes the contents of the given files to
+// its stdout, one after the other, like cat.  Relative names are relative to
+// the directory set with WithDir.  With no files, it copies its stdin to its
+// stdout instead.  Like Echo, it runs no program.
+func Cat(files ...string) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTask(s, func(ctx context.Context, s *pipe.State) error {
+			w := ctxWriter{ctx, s.Stdout}
+			if len(files) == 0 {
+				_, err := io.Copy(w, ctxReader{ctx, stdin(s)})
+				return pipeError(err)
+			}
+			for _, name := range files {
+				if err := catFile(w, s.Path(name)); err != nil {
+					return pipeError(err)
+				}
+			}
+			return nil
+		})
+	}}
+}
+
+// Sleep returns an Executable that waits for d, then copies its stdin to its
+// stdout, so that it can slow down a Pipe or stand in for a slow command, as
+// when testing timeouts.  Like the other stages written in Go, it stops
+// waiting as soon as it is killed, such as when a timeout passes.
+func Sleep(d time.Duration) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTask(s, func(ctx context.Context, s *pipe.State) error {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			_, err := io.Copy(ctxWriter{ctx, s.Stdout}, ctxReader{ctx, stdin(s)})
+			return pipeError(err)
+		})
+	}}
+}
+
+// Hash returns an Executable that copies its stdin to its stdout unchanged,
+// while writing it to h too, and a function that returns the digest of what
+// was copied.  This checksums a stream without reading it a second time.  The
+// digest function must only be called after the Executable has finished
+// running; each time the Executable is run, h is reset first.
+func Hash(h hash.Hash) (Executable, func() []byte) {
+	c := Func(func(r io.Reader, w io.Writer) error {
+		h.Reset()
+		_, err := io.Copy(io.MultiWriter(h, w), r)
+		return err
+	})
+	return c, func() []byte { return h.Sum(nil) }
+}
+
+// catFile copies the contents of the named file to w.
+func catFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Head returns an Executable that copies the first n lines of its stdin to its
+// stdout, like head -n.  Once it has copied them it stops reading, so that
+// the stages before it in a Pipe are stopped as well, rather than running to
+// completion; on Unix, commands are stopped by SIGPIPE, as in the shell.
+// Being stopped that way is not an error.
+func Head(n int) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		if n <= 0 {
+			return nil
+		}
+		i := 0
+		err := forEachLine(r, func(line string, eol bool) error {
+			if err := writeLine(w, line, eol); err != nil {
+				return err
+			}
+			if i++; i == n {
+				return errStop
+			}
+			return nil
+		})
+		if err == errStop {
+			return nil
+		}
+		return err
+	})
+}
+
+// errStop stops forEachLine early without an error.
+var errStop = errors.New("stop")
+
+// Tail returns an Executable that copies the last n lines of its stdin to its
+// stdout, like tail -n.  It has to read all of its stdin first, but only keeps
+// n lines in memory.
+func Tail(n int) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		if n <= 0 {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		}
+		// ring holds the last n lines read, the oldest at ring[count%n] once
+		// it is full.
+		ring := make([]string, n)
+		count := 0
+		eol := true
+		err := forEachLine(r, func(line string, e bool) error {
+			ring[count%n] = line
+			count++
+			eol = e
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		start, k := 0, count
+		if count > n {
+			start, k = count%n, n
+		}
+		for i := 0; i < k; i++ {
+			if err := writeLine(w, ring[(start+i)%n], eol || i < k-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ErrNoMatch is the error returned by Grep and GrepV when no lines are written,
+// if the NoMatchError option is given.
+var ErrNoMatch = errors.New("no lines matched")
+
+// Grep returns an Executable that copies the lines of its stdin that match the
+// regular expression pattern to its stdout, like grep -E.  Patterns use the
+// syntax of the regexp package.  By default, nothing matching is not an
+// error; see NoMatchError.
+func Grep(pattern string, opts ...GrepOption) Executable {
+	return grep(pattern, false, opts)
+}
+
+// GrepV works like Grep, but copies the lines that do not match pattern, like
+// grep -v.
+func GrepV(pattern string, opts ...GrepOption) Executable {
+	return grep(pattern, true, opts)
+}
+
+// GrepOption configures Grep and GrepV.
+type GrepOption func(*grepOptions)
+
+type grepOptions struct {
+	noMatchError bool
+}
+
+// NoMatchError makes Grep and GrepV fail with ErrNoMatch if no lines are
+// written, as grep does by exiting 1.
+func NoMatchError() GrepOption {
+	return func(o *grepOptions) {
+		o.noMatchError = true
+	}
+}
+
+func grep(pattern string, invert bool, opts []GrepOption) Executable {
+	var o grepOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Executable{func(*pipe.State) error { return err }}
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		matched := false
+		err := forEachLine(r, func(line string, eol bool) error {
+			if re.MatchString(line) == invert {
+				return nil
+			}
+			matched = true
+			return writeLine(w, line, eol)
+		})
+		if err == nil && !matched && o.noMatchError {
+			return ErrNoMatch
+		}
+		return err
+	})
+}
+
+// pipeError returns err as the error of a stage written in Go, or nil if it
+// only failed because the stage after it stopped reading.
+func pipeError(err error) error {
+	if brokenPipe(err) {
+		return nil
+	}
+	return err
+}
+
+// forEachLine calls fn with each line read from r, minus its newline.  eol
+// reports whether the line ended in a newline, which only the last line may
+// not.  Reading stops at the first error from fn, which is returned.
+func forEachLine(r io.Reader, fn func(line string, eol bool) error) error {
+	return forEachRecord(r, '\n', fn)
+}
+
+// forEachRecord works like forEachLine, for records that end with delim.
+func forEachRecord(r io.Reader, delim byte, fn func(rec string, end bool) error) error {
+	br := bufio.NewReader(r)
+	for {
+		rec, err := br.ReadString(delim)
+		if rec != "" {
+			end := rec[len(rec)-1] == delim
+			if end {
+				rec = rec[:len(rec)-1]
+			}
+			if ferr := fn(rec, end); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeLine writes line to w, followed by a newline if eol is true.
+func writeLine(w io.Writer, line string, eol bool) error {
+	return writeRecord(w, line, '\n', eol)
+}
+
+// writeRecord writes rec to w, followed by delim if end is true.
+func writeRecord(w io.Writer, rec string, delim byte, end bool) error {
+	if end {
+		rec += string(delim)
+	}
+	_, err := io.WriteString(w, rec)
+	return err
+}
+
+// ctxReader is an io.Reader that fails once its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ctxWriter is an io.Writer that fails once its context is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
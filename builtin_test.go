@@ -0,0 +1,248 @@
+package sh_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleFunc() {
+	grep := sh.Cmd("grep")
+
+	// reverse each line that comes through
+	reverse := sh.Func(func(r io.Reader, w io.Writer) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			runes := []rune(scanner.Text())
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			if _, err := fmt.Fprintln(w, string(runes)); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	})
+
+	fmt.Print(sh.PipeWith(SWCrawl, grep("far"), reverse))
+	// output:
+	// ....yawa raf ,raf yxalag a ni ,oga emit gnol A
+}
+
+func ExampleMapLines() {
+	grep := sh.Cmd("grep")
+	quote := sh.MapLines(func(line string) string {
+		return "> " + line
+	})
+
+	fmt.Print(sh.PipeWith(SWCrawl, grep("Rebel"), quote))
+	// output:
+	// > It is a period of civil war. Rebel
+	// > During the battle, Rebel spies managed
+}
+
+func ExamplePrefix() {
+	build := sh.Shell("echo compiling; printf done")
+
+	fmt.Println(sh.Pipe(build, sh.Prefix("[api] ")))
+	// output:
+	// [api] compiling
+	// [api] done
+}
+
+func ExampleEcho() {
+	fmt.Print(sh.Pipe(sh.Echo("Hi", "there!"), sh.Grep("Hi")))
+	// output:
+	// Hi there!
+}
+
+func ExampleSleep() {
+	start := time.Now()
+	out, err := sh.PipeWith("slow\n", sh.Sleep(20*time.Millisecond)).Run()
+	fmt.Print(out)
+	fmt.Println(err, time.Since(start) >= 20*time.Millisecond)
+	// output:
+	// slow
+	// <nil> true
+}
+
+func TestSleepCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := sh.Sleep(10*time.Second).RunContext(ctx, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %v to stop", d)
+	}
+
+	_, err = sh.WithTimeout(20*time.Millisecond, sh.Sleep(10*time.Second)).Run()
+	var timeout *sh.TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("got error %v, want a *TimeoutError", err)
+	}
+}
+
+func ExampleCat() {
+	dir, err := ioutil.TempDir("", "cat")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTempFile(filepath.Join(dir, "a.txt"), "first\n")
+	writeTempFile(filepath.Join(dir, "b.txt"), "second\n")
+
+	fmt.Print(sh.Cat("a.txt", "b.txt").WithDir(dir))
+	// output:
+	// first
+	// second
+}
+
+func ExampleHash() {
+	hashed, digest := sh.Hash(sha256.New())
+
+	out, err := sh.PipeWith("hello\n", hashed, sh.Cmd("tr")("a-z", "A-Z")).Run()
+	fmt.Print(out)
+	fmt.Println(err)
+	fmt.Printf("%x\n", digest())
+	// output:
+	// HELLO
+	// <nil>
+	// 5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03
+}
+
+func ExampleHead() {
+	yes := sh.Cmd("yes")
+
+	// yes never stops on its own, so this only returns because Head stops it.
+	fmt.Print(sh.Pipe(yes("y"), sh.Head(3)))
+	// output:
+	// y
+	// y
+	// y
+}
+
+func ExampleTail() {
+	fmt.Print(sh.PipeWith(SWCrawl, sh.Tail(2)))
+	// output:
+	// that can save her people and restore
+	// freedom to the galaxy...
+}
+
+func TestHeadTail(t *testing.T) {
+	tests := []struct {
+		name string
+		c    sh.Executable
+		in   string
+		want string
+	}{
+		{"head all", sh.Head(5), "a\nb\n", "a\nb\n"},
+		{"head zero", sh.Head(0), "a\nb\n", ""},
+		{"head no newline", sh.Head(2), "a\nb", "a\nb"},
+		{"tail all", sh.Tail(5), "a\nb\n", "a\nb\n"},
+		{"tail some", sh.Tail(2), "a\nb\nc\nd\n", "c\nd\n"},
+		{"tail zero", sh.Tail(0), "a\nb\n", ""},
+		{"tail no newline", sh.Tail(2), "a\nb\nc", "b\nc"},
+		{"tail empty", sh.Tail(2), "", ""},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, tt.c).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+}
+
+func TestHeadStopsGoStages(t *testing.T) {
+	big := strings.Repeat("line\n", 100000)
+	out, err := sh.Pipe(sh.Echo(big), sh.MapLines(strings.ToUpper), sh.Head(1)).Run()
+	if err != nil || out != "LINE\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "LINE\n")
+	}
+}
+
+func TestCatStdin(t *testing.T) {
+	out, err := sh.PipeWith("in\n", sh.Cat()).Run()
+	if err != nil || out != "in\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "in\n")
+	}
+}
+
+func TestCatMissing(t *testing.T) {
+	_, err := sh.Cat("does-not-exist").Run()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want one that is os.ErrNotExist", err)
+	}
+}
+
+func TestMapLines(t *testing.T) {
+	upper := sh.MapLines(strings.ToUpper)
+	long := strings.Repeat("x", 1<<20)
+
+	tests := map[string]string{
+		"":                 "",
+		"a\nb\n":           "A\nB\n",
+		"a\nb":             "A\nB",
+		"\n\n":             "\n\n",
+		long + "\n" + long: strings.ToUpper(long + "\n" + long),
+	}
+	for in, expected := range tests {
+		out, err := upper.RunWith(in)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if out != expected {
+			t.Errorf("expected %.20q, got %.20q", expected, out)
+		}
+	}
+}
+
+func ExampleGrep() {
+	fmt.Print(sh.PipeWith(SWCrawl, sh.Grep(`^[A-Z].*(Rebel|Empire)`), sh.GrepV("Pursued")))
+	// output:
+	// It is a period of civil war. Rebel
+	// During the battle, Rebel spies managed
+}
+
+func TestGrepNoMatch(t *testing.T) {
+	out, err := sh.PipeWith(SWCrawl, sh.Grep("Vader")).Run()
+	if out != "" || err != nil {
+		t.Errorf("expected no output and no error, got %q, %v", out, err)
+	}
+
+	_, err = sh.PipeWith(SWCrawl, sh.Grep("Vader", sh.NoMatchError())).Run()
+	if err != sh.ErrNoMatch {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+
+	_, err = sh.PipeWith(SWCrawl, sh.Grep("(")).Run()
+	if err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestFuncError(t *testing.T) {
+	cat := sh.Cmd("cat")
+	fail := sh.Func(func(r io.Reader, w io.Writer) error {
+		io.Copy(io.Discard, r)
+		return errors.New("bad input")
+	})
+
+	_, err := sh.PipeWith(SWCrawl, cat(), fail, cat()).Run()
+	if err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Errorf("expected bad input error, got %v", err)
+	}
+}
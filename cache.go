@@ -0,0 +1,115 @@
+package sh
+
+import (
+	"context"
+	"sync"
+
+	"labix.org/v2/pipe"
+)
+
+// Cache stores the output of commands run with Cached.  Its methods may be
+// called from several goroutines at once.  Implementing it with files lets
+// output be kept from one run of a program to the next.
+type Cache interface {
+	// Get returns the output stored for key, and whether there is any.
+	Get(key string) ([]byte, bool)
+	// Set stores out as the output for key.  out must not be changed
+	// afterwards.
+	Set(key string, out []byte)
+}
+
+// MemoryCache is a Cache that keeps output in memory.  The zero value is an
+// empty cache ready to use.
+type MemoryCache struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+// Get returns the output stored for key, and whether there is any.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.m[key]
+	return out, ok
+}
+
+// Set stores out as the output for key.
+func (c *MemoryCache) Set(key string, out []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[string][]byte)
+	}
+	c.m[key] = out
+}
+
+// DefaultCache is the Cache used by Cached.  It keeps output in memory for as
+// long as the process runs.  It should only be changed while no Executables
+// are running, such as at the start of main or of a test.
+var DefaultCache Cache = &MemoryCache{}
+
+// Cached returns an Executable that runs cmd only if DefaultCache has no
+// output for key, and stores cmd's stdout there for key if cmd succeeds.  If
+// there is output for key, it is written to stdout without running anything.
+// This saves running costly commands whose output won't change, like git
+// describe, over and over:
+//
+//	version := sh.Cached("version", git("describe", "--tags"))
+//
+// key must stand for everything the output depends on, including the stdin
+// of cmd, which is not read when the output is cached.  The output of cmd is
+// written to stdout only once cmd has finished.  Its stderr is not cached.  If
+// cmd fails, its output is written but not cached, and cmd is run again the
+// next time.  Cached Executables for the same key may be run at once; then
+// only one of them runs cmd while the others wait for its output.
+func Cached(key string, cmd Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		cache := DefaultCache
+		return addTaskFor(s, []Executable{cmd}, func(ctx context.Context, s *pipe.State) error {
+			unlock, err := lockKey(ctx, key)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			if out, ok := cache.Get(key); ok {
+				_, err := s.Stdout.Write(out)
+				return pipeError(err)
+			}
+			out := &buffer{}
+			err = cmd.runInWith(ctx, s, s.Stdin, out, s.Stderr)
+			if err == nil {
+				cache.Set(key, out.Bytes())
+			}
+			if _, werr := s.Stdout.Write(out.Bytes()); err == nil {
+				err = pipeError(werr)
+			}
+			return err
+		})
+	}}
+}
+
+// keyLocks holds a lock for each key that Cached has been run with, so that
+// only one command is run for a key at a time.  A lock is a channel holding a
+// value while the key is locked.
+var keyLocks = struct {
+	sync.Mutex
+	m map[string]chan struct{}
+}{m: make(map[string]chan struct{})}
+
+// lockKey locks key, or fails if ctx is done first, and returns the function
+// that unlocks it.
+func lockKey(ctx context.Context, key string) (unlock func(), err error) {
+	keyLocks.Lock()
+	lock, ok := keyLocks.m[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		keyLocks.m[key] = lock
+	}
+	keyLocks.Unlock()
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
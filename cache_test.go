@@ -0,0 +1,85 @@
+package sh_test
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleCached() {
+	date := sh.Cmd("date")
+
+	start := sh.Cached("start", date("+%s%N"))
+	first := start.String()
+	fmt.Println(start.String() == first)
+	// output:
+	// true
+}
+
+// counter returns an Executable that writes how many times it has been run,
+// and fails if fail is set.
+func counter(fail *bool) (sh.Executable, *int32) {
+	var n int32
+	return sh.Func(func(_ io.Reader, w io.Writer) error {
+		fmt.Fprintln(w, atomic.AddInt32(&n, 1))
+		if *fail {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}), &n
+}
+
+func TestCached(t *testing.T) {
+	defer func(old sh.Cache) { sh.DefaultCache = old }(sh.DefaultCache)
+	sh.DefaultCache = &sh.MemoryCache{}
+	fail := true
+	c, n := counter(&fail)
+	cached := sh.Cached("TestCached", c)
+
+	// Failures are not cached.
+	if out, err := cached.Run(); err == nil || out != "1\n" {
+		t.Errorf("got %q, %v, want %q and an error", out, err, "1\n")
+	}
+	fail = false
+	for i := 0; i < 3; i++ {
+		out, err := cached.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "2\n" {
+			t.Errorf("run %d: got %q, want %q", i+1, out, "2\n")
+		}
+	}
+	if *n != 2 {
+		t.Errorf("ran %d times, want 2", *n)
+	}
+	if out := sh.Cached("other", c).String(); out != "3\n" {
+		t.Errorf("got %q for another key, want %q", out, "3\n")
+	}
+}
+
+func TestCachedConcurrent(t *testing.T) {
+	defer func(old sh.Cache) { sh.DefaultCache = old }(sh.DefaultCache)
+	sh.DefaultCache = &sh.MemoryCache{}
+	fail := false
+	c, n := counter(&fail)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if out := sh.Pipe(sh.Cached("TestCachedConcurrent", c), sh.Cat()).String(); out != "1\n" {
+				t.Errorf("got %q, want %q", out, "1\n")
+			}
+		}()
+	}
+	wg.Wait()
+	if *n != 1 {
+		t.Errorf("ran %d times, want 1", *n)
+	}
+}
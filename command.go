@@ -0,0 +1,165 @@
+package sh
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"labix.org/v2/pipe"
+)
+
+// Command returns an Executable that runs the named command, configured by the
+// given options.
+//
+//	ls := sh.Command("ls", sh.Args("-l"), sh.Dir("/tmp"), sh.Timeout(time.Second))
+func Command(name string, opts ...Option) Executable {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := Executable{execPipe(name, o.args...)}
+	if len(o.subs) > 0 {
+		c = o.substitute(name)
+	}
+	if o.stdin != nil {
+		c = c.WithStdin(o.stdin)
+	}
+	if o.env != nil {
+		c = c.WithEnv(o.env...)
+	}
+	if o.dir != "" {
+		c = c.WithDir(o.dir)
+	}
+	if o.timeout > 0 {
+		c = WithTimeout(o.timeout, c)
+	}
+	return c
+}
+
+// Option configures an Executable created by Command.
+type Option func(*options)
+
+// options holds the configuration built up by a list of Options.
+type options struct {
+	args    []string
+	env     []string
+	dir     string
+	timeout time.Duration
+	stdin   io.Reader
+	subs    []sub
+}
+
+// sub is a command whose output is spliced into the arguments of another.
+type sub struct {
+	// at is the number of args that come before the output.
+	at  int
+	cmd Executable
+}
+
+// Args adds arguments to the command.  Args may be given more than once, and
+// the arguments are passed in the order they were given.
+func Args(args ...string) Option {
+	return func(o *options) {
+		o.args = append(o.args, args...)
+	}
+}
+
+// Glob returns the names of the files matching pattern, sorted, for passing as
+// arguments to a command, since without a shell nothing expands wildcards:
+//
+//	grep(append([]string{"TODO"}, sh.Glob("*.go")...)...)
+//
+// The pattern syntax is that of filepath.Match.  Unlike the shell, which passes
+// a pattern that matches nothing along as is, Glob returns an empty slice when
+// nothing matches, and also when the pattern is malformed.
+func Glob(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	return matches
+}
+
+// Sub adds the output of cmd to the arguments of the command, like $(cmd) in
+// the shell:
+//
+//	kill := sh.Command("kill", sh.Sub(sh.Cmd("pgrep")("foo")))
+//
+// cmd is run each time the command is, just before it starts, in the same
+// directory and environment, with no stdin.  Its stdout is split into
+// arguments at runs of white space, as the shell splits an unquoted $(cmd),
+// so output with no words adds no arguments.  Wildcards in the output are not
+// expanded.  If cmd fails, the command is not run, and the error is cmd's.
+func Sub(cmd Executable) Option {
+	return func(o *options) {
+		o.subs = append(o.subs, sub{at: len(o.args), cmd: cmd})
+	}
+}
+
+// substitute returns an Executable that runs the named command with o.args
+// and the output of o.subs as its arguments.  In a dry run, the output of
+// each sub is shown as $(...).
+func (o options) substitute(name string) Executable {
+	cmds := make([]Executable, 0, len(o.subs)+1)
+	placeholders := make([][]string, len(o.subs))
+	for i, sub := range o.subs {
+		cmds = append(cmds, sub.cmd)
+		placeholders[i] = []string{"$(...)"}
+	}
+	cmds = append(cmds, Executable{execPipe(name, o.splice(placeholders)...)})
+
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			outputs := make([][]string, len(o.subs))
+			for i, sub := range o.subs {
+				out := &buffer{}
+				if err := sub.cmd.runInWith(ctx, s, strings.NewReader(""), out, s.Stderr); err != nil {
+					return err
+				}
+				outputs[i] = strings.Fields(out.String())
+			}
+			return Executable{execPipe(name, o.splice(outputs)...)}.runIn(ctx, s)
+		})
+	}}
+}
+
+// splice returns o.args with outputs[i] inserted where o.subs[i] was given.
+func (o options) splice(outputs [][]string) []string {
+	var args []string
+	prev := 0
+	for i, sub := range o.subs {
+		args = append(append(args, o.args[prev:sub.at]...), outputs[i]...)
+		prev = sub.at
+	}
+	return append(args, o.args[prev:]...)
+}
+
+// Env sets the environment of the command, as WithEnv does.  Env may be given
+// more than once, and all the variables are used.
+func Env(env ...string) Option {
+	return func(o *options) {
+		o.env = append(o.env, env...)
+	}
+}
+
+// Dir sets the working directory of the command, as WithDir does.
+func Dir(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+// Timeout kills the command if it runs longer than d, as WithTimeout does.
+func Timeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// Stdin sets the standard input of the command to r, as WithStdin does, even
+// when the command is a stage in a Pipe.  Since r can only be read once, an
+// Executable with this option should only be run once.
+func Stdin(r io.Reader) Option {
+	return func(o *options) {
+		o.stdin = r
+	}
+}
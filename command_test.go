@@ -0,0 +1,64 @@
+package sh_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleGlob() {
+	dir, err := os.MkdirTemp("", "ExampleGlob")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.txt", "a.txt", "c.log"} {
+		defer writeTempFile(filepath.Join(dir, name), name)()
+	}
+
+	for _, name := range sh.Glob(filepath.Join(dir, "*.txt")) {
+		fmt.Println(filepath.Base(name))
+	}
+	// output:
+	// a.txt
+	// b.txt
+}
+
+func TestGlobNoMatch(t *testing.T) {
+	for _, pattern := range []string{"/no/such/dir/*", "[malformed"} {
+		if matches := sh.Glob(pattern); len(matches) != 0 {
+			t.Errorf("Glob(%q): expected no matches, got %q", pattern, matches)
+		}
+	}
+}
+
+func ExampleSub() {
+	echo := sh.Command("echo", sh.Args("files:"), sh.Sub(sh.Echo("a.txt  b.txt\n c.txt")), sh.Args("done"))
+	fmt.Print(echo)
+	// output:
+	// files: a.txt b.txt c.txt done
+}
+
+func TestSub(t *testing.T) {
+	count := sh.Command("sh", sh.Args("-c", `echo $#`, "sh"), sh.Sub(sh.Echo("")), sh.Sub(sh.Echo("x")))
+	if out, err := count.Run(); err != nil || out != "1\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "1\n")
+	}
+
+	out, err := sh.Command("echo", sh.Args("ran"), sh.Sub(sh.Shell("exit 3"))).Run()
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || out != "" {
+		t.Errorf("got %q, %v, want no output and exit status 3", out, err)
+	}
+
+	sh.DryRun = true
+	defer func() { sh.DryRun = false }()
+	out, err = sh.Command("kill", sh.Args("-9"), sh.Sub(sh.Command("pgrep", sh.Args("foo")))).Run()
+	if want := "pgrep foo\nkill -9 '$(...)'\n"; err != nil || out != want {
+		t.Errorf("dry run: got %q, %v, want %q, nil", out, err, want)
+	}
+}
@@ -0,0 +1,65 @@
+package sh
+
+import (
+	"compress/gzip"
+	"io"
+
+	"labix.org/v2/pipe"
+)
+
+// Gzip returns an Executable that compresses its stdin with gzip and writes
+// the result to its stdout, like gzip -c.  Data is compressed as it streams
+// through, so a stage can compress more than fits in memory.  It runs no
+// program, so it works without a gzip binary.
+func Gzip(opts ...GzipOption) Executable {
+	o := gzipOptions{level: gzip.DefaultCompression}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, o.level); err != nil {
+		return Executable{func(*pipe.State) error { return err }}
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		zw, _ := gzip.NewWriterLevel(w, o.level)
+		if _, err := io.Copy(zw, r); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// GzipOption configures Gzip.
+type GzipOption func(*gzipOptions)
+
+type gzipOptions struct {
+	level int
+}
+
+// GzipLevel sets the compression level of Gzip, from gzip.BestSpeed to
+// gzip.BestCompression, or one of the other levels of compress/gzip.  The
+// default is gzip.DefaultCompression.  An invalid level makes running Gzip
+// fail.
+func GzipLevel(level int) GzipOption {
+	return func(o *gzipOptions) {
+		o.level = level
+	}
+}
+
+// Gunzip returns an Executable that decompresses the gzip data of its stdin
+// and writes the result to its stdout, like gunzip -c.  Like Gzip, it streams
+// and runs no program.  Data that is not valid gzip is an error.
+func Gunzip() Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		zr, err := gzip.NewReader(r)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, zr); err != nil {
+			return err
+		}
+		return zr.Close()
+	})
+}
@@ -0,0 +1,40 @@
+package sh_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleGzip() {
+	fmt.Print(sh.PipeWith(SWCrawl, sh.Gzip(), sh.Gunzip(), sh.Grep("Leia")))
+	// output:
+	// Princess Leia races home aboard her
+}
+
+func TestGzipLevel(t *testing.T) {
+	fast, err := sh.PipeWith(SWCrawl, sh.Gzip(sh.GzipLevel(gzip.NoCompression))).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, err := sh.PipeWith(SWCrawl, sh.Gzip(sh.GzipLevel(gzip.BestCompression))).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best) >= len(fast) {
+		t.Errorf("best compression gave %d bytes, no compression %d", len(best), len(fast))
+	}
+	if _, err := sh.Gzip(sh.GzipLevel(42)).Run(); err == nil {
+		t.Error("no error for an invalid level")
+	}
+}
+
+func TestGunzipInvalid(t *testing.T) {
+	for _, in := range []string{"", "not gzip"} {
+		if _, err := sh.PipeWith(in, sh.Gunzip()).Run(); err == nil {
+			t.Errorf("no error for %q", in)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package sh
+
+import (
+	"strings"
+
+	"labix.org/v2/pipe"
+)
+
+// DryRun, when true, stops Executables from running anything, so that scripts
+// can be checked before they are let loose.  Running an Executable instead
+// succeeds without starting any process, and its stdout lists the commands it
+// would have started, one per line:
+//
+//   - Each line is the command's name and arguments, quoted the way WithTrace
+//     quotes them, without the leading "+ ".
+//   - Commands are listed in the order they appear in the Executable, so the
+//     stages of a Pipe are listed first to last.
+//   - Commands under And, Or, Seq, Concat, WithTimeout, and Retry are listed
+//     once each, whether or not they would really have run.
+//   - Stages written in Go, such as Func, Grep, Dump, and ToFile, are neither
+//     listed nor run.
+//
+// For example, with DryRun set, Pipe(echo("Hi there!"), grep("-o", "Hi")).Run()
+// returns "echo 'Hi there!'\ngrep -o Hi\n".  DryRun must not be changed
+// while Executables are running.
+var DryRun bool
+
+// dryRun sets up c without running it, and returns the list of commands
+// described by DryRun.
+func (c Executable) dryRun() (string, error) {
+	cmds, err := c.plan()
+	var b strings.Builder
+	for _, cmd := range cmds {
+		b.WriteString(commandLine(cmd.redact.string(cmd.name), cmd.redact.args(cmd.args)) + "\n")
+	}
+	return b.String(), err
+}
+
+// plan sets up c without running it, and returns the commands it would run.
+func (c Executable) plan() ([]plannedCmd, error) {
+	var cmds []plannedCmd
+	s := pipe.NewState(nil, nil)
+	err := withSettings(c.Pipe, func(st *settings) { st.commands = &cmds })(s)
+	return cmds, err
+}
@@ -0,0 +1,50 @@
+package sh_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func TestDryRun(t *testing.T) {
+	sh.DryRun = true
+	defer func() { sh.DryRun = false }()
+
+	echo := sh.Cmd("echo")
+	grep := sh.Cmd("grep")
+	rm := sh.Cmd("rm")
+	test := sh.Cmd("test")
+
+	name := "TestDryRunFile"
+	defer writeTempFile(name, SWCrawl)()
+
+	pipe := sh.Pipe(echo("Hi there!"), sh.Grep("Hi"), grep("-o", "Hi"), sh.ToFile(name))
+	tests := []struct {
+		cmd      sh.Executable
+		expected string
+	}{
+		{pipe, "echo 'Hi there!'\ngrep -o Hi\n"},
+		{rm(name), "rm " + name + "\n"},
+		{sh.And(test("-f", name), sh.WithTimeout(time.Second, rm(name))), "test -f " + name + "\nrm " + name + "\n"},
+		{sh.Grep("x"), ""},
+	}
+	for _, tt := range tests {
+		out, err := tt.cmd.Run()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if out != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, out)
+		}
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("expected %s to still exist: %v", name, err)
+	}
+	if string(b) != SWCrawl {
+		t.Errorf("expected %s to be left alone, got %q", name, b)
+	}
+}
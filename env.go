@@ -0,0 +1,182 @@
+package sh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvFile reads the dotenv file at path, and returns the variables it sets as
+// KEY=VALUE pairs, in the order they are set, ready to be passed to WithEnv or
+// WithExtraEnv:
+//
+//	env, err := sh.EnvFile(".env")
+//	if err != nil {
+//		return err
+//	}
+//	_, err = app("serve").WithExtraEnv(env...).Run()
+//
+// Each line of the file sets a variable, as KEY=VALUE, optionally after
+// export.  Blank lines and lines starting with # are ignored, as is the rest
+// of a line after a # that follows white space.  White space around an
+// unquoted value is trimmed.  A value in single quotes is taken as it is; in
+// double quotes, \n, \r, \t, \", \\ and \$ are replaced with what they stand
+// for.  Quoted values may span lines.  Variables such as $HOME or ${HOME} are
+// not expanded, since that would depend on the environment of the process
+// reading the file.
+func EnvFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := envParser{name: path, data: string(b), line: 1}
+	return p.parse()
+}
+
+// envParser holds the state of EnvFile as it works through a file.
+type envParser struct {
+	name string
+	data string
+	// line is the number of the line that data starts on.
+	line int
+}
+
+func (p *envParser) parse() ([]string, error) {
+	var env []string
+	for p.data != "" {
+		p.skip(" \t")
+		switch {
+		case p.data == "":
+		case p.data[0] == '\n' || p.data[0] == '\r':
+			p.advance(1)
+		case p.data[0] == '#':
+			p.skipComment()
+		default:
+			kv, err := p.variable()
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, kv)
+		}
+	}
+	return env, nil
+}
+
+// variable parses a line that sets a variable, and returns it as KEY=VALUE.
+func (p *envParser) variable() (string, error) {
+	if rest, ok := strings.CutPrefix(p.data, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		p.advance(len("export"))
+		p.skip(" \t")
+	}
+	end := strings.IndexAny(p.data, "=\n")
+	if end < 0 || p.data[end] != '=' {
+		return "", p.errorf("missing =")
+	}
+	key := strings.TrimRight(p.data[:end], " \t")
+	if !assignment.MatchString(key + "=") {
+		return "", p.errorf("invalid variable name %q", key)
+	}
+	p.advance(end + 1)
+	spaced := strings.HasPrefix(p.data, " ") || strings.HasPrefix(p.data, "\t")
+	p.skip(" \t")
+	var value string
+	var err error
+	switch {
+	case strings.HasPrefix(p.data, "'"):
+		value, err = p.singleQuoted()
+	case strings.HasPrefix(p.data, `"`):
+		value, err = p.doubleQuoted()
+	default:
+		end := strings.IndexByte(p.data, '\n')
+		if end < 0 {
+			end = len(p.data)
+		}
+		value = p.data[:end]
+		for i := 0; i < len(value); i++ {
+			if value[i] == '#' && (i == 0 && spaced || i > 0 && (value[i-1] == ' ' || value[i-1] == '\t')) {
+				value = value[:i]
+				break
+			}
+		}
+		p.advance(len(value))
+		value = strings.TrimRight(value, " \t\r")
+	}
+	if err != nil {
+		return "", err
+	}
+	// After a quoted value, only a comment may follow.
+	p.skip(" \t\r")
+	if p.data != "" && p.data[0] == '#' {
+		p.skipComment()
+	}
+	if p.data != "" && p.data[0] != '\n' {
+		return "", p.errorf("unexpected %q after value", p.data[0])
+	}
+	return key + "=" + value, nil
+}
+
+// singleQuoted parses a value in single quotes.
+func (p *envParser) singleQuoted() (string, error) {
+	end := strings.IndexByte(p.data[1:], '\'')
+	if end < 0 {
+		return "", p.errorf("unterminated single quote")
+	}
+	value := p.data[1 : 1+end]
+	p.advance(end + 2)
+	return value, nil
+}
+
+// doubleQuoted parses a value in double quotes.
+func (p *envParser) doubleQuoted() (string, error) {
+	var value strings.Builder
+	for i := 1; i < len(p.data); i++ {
+		c := p.data[i]
+		switch {
+		case c == '"':
+			p.advance(i + 1)
+			return value.String(), nil
+		case c == '\\' && i+1 < len(p.data):
+			i++
+			switch c := p.data[i]; c {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '"', '\\', '$':
+				value.WriteByte(c)
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(c)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated double quote")
+}
+
+// skip skips past any of the bytes in chars at the start of the data.
+func (p *envParser) skip(chars string) {
+	p.advance(len(p.data) - len(strings.TrimLeft(p.data, chars)))
+}
+
+// skipComment skips to the end of the line.
+func (p *envParser) skipComment() {
+	end := strings.IndexByte(p.data, '\n')
+	if end < 0 {
+		end = len(p.data)
+	}
+	p.advance(end)
+}
+
+// advance moves n bytes further into the data.
+func (p *envParser) advance(n int) {
+	p.line += strings.Count(p.data[:n], "\n")
+	p.data = p.data[n:]
+}
+
+func (p *envParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("parsing %s: %s at line %d", p.name, fmt.Sprintf(format, args...), p.line)
+}
@@ -0,0 +1,89 @@
+package sh_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleEnvFile() {
+	dir, err := os.MkdirTemp("", "envfile")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".env")
+	os.WriteFile(path, []byte("# settings for the example\nGREETING='Hello there'\nNAME=Obi-Wan # a Jedi\n"), 0666)
+
+	env, err := sh.EnvFile(path)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(sh.Shell(`echo "$GREETING, $NAME"`).WithExtraEnv(env...))
+	// output:
+	// Hello there, Obi-Wan
+}
+
+func TestEnvFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"", nil},
+		{"\n\n  \n# just a comment\n", nil},
+		{"A=1\nB=2", []string{"A=1", "B=2"}},
+		{"A=1\r\nB=2\r\n", []string{"A=1", "B=2"}},
+		{"export A=1\n\texport\tB = 2 \n", []string{"A=1", "B=2"}},
+		{"export=1\n", []string{"export=1"}},
+		{"A=\nB= \n", []string{"A=", "B="}},
+		{"A=a b  # comment\nB=#not a comment\nC=x#y\nD= # empty\n", []string{"A=a b", "B=#not a comment", "C=x#y", "D="}},
+		{`A='a "b" \n $c # d'`, []string{`A=a "b" \n $c # d`}},
+		{`A="a \"b\" \\ \$c\n\t# d"`, []string{"A=a \"b\" \\ $c\n\t# d"}},
+		{`A="\x"`, []string{`A=\x`}},
+		{"A='two\nlines'\nB=\"and\nthese\"\n", []string{"A=two\nlines", "B=and\nthese"}},
+		{"A=1\nA=2\n", []string{"A=1", "A=2"}},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(path, []byte(tt.file), 0666); err != nil {
+			t.Fatal(err)
+		}
+		got, err := sh.EnvFile(path)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("file %q: got %q, %v, want %q, nil", tt.file, got, err, tt.want)
+		}
+	}
+}
+
+func TestEnvFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"A=1\nB\n":            "missing = at line 2",
+		"A=1\n\n1A=2\n":       `invalid variable name "1A" at line 3`,
+		"A B=1":               `invalid variable name "A B" at line 1`,
+		"A='unterminated\n\n": "unterminated single quote at line 1",
+		"\nA=\"unterminated":  "unterminated double quote at line 2",
+		"A='a'b\n":            `unexpected 'b' after value at line 1`,
+		"A='it''s'\n":         `unexpected '\'' after value at line 1`,
+		"A=\"a\nb\" c\nD=1":   `unexpected 'c' after value at line 2`,
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	for file, want := range tests {
+		if err := os.WriteFile(path, []byte(file), 0666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := sh.EnvFile(path)
+		if err == nil || err.Error() != "parsing "+path+": "+want {
+			t.Errorf("file %q: got error %v, want %q", file, err, want)
+		}
+	}
+
+	if _, err := sh.EnvFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v, want an error that it doesn't exist", err)
+	}
+}
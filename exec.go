@@ -0,0 +1,837 @@
+package sh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"labix.org/v2/pipe"
+)
+
+// ErrNotFound is the error, matched with errors.Is, for a command whose
+// executable can't be found: not in PATH or, for a name with a path separator
+// in it, not at that path.  It is exec.ErrNotFound.  A command that is found
+// but exits with a non-zero status fails with an *ExitError instead:
+//
+//	switch _, err := git("status").Run(); {
+//	case errors.Is(err, sh.ErrNotFound):
+//		log.Fatal("git is not installed")
+//	case err != nil:
+//		log.Fatalf("git status failed: %v", err)
+//	}
+var ErrNotFound = exec.ErrNotFound
+
+// notFoundError is the error for a command named with a path that doesn't
+// exist, which os/exec reports only as the error it got from the system.  It
+// is ErrNotFound as well.
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notFoundError) Unwrap() []error {
+	return []error{e.err, ErrNotFound}
+}
+
+// ExitError is the error returned when a command runs but exits with a
+// non-zero status.  Its message names the command line, the stage, and what
+// the command wrote to stderr, like
+//
+//	sh: "grep -o Hi" (stage 2) exited 2: grep: bad regex
+type ExitError struct {
+	// Name is the name of the command that failed.
+	Name string
+	// Args are the arguments the command was run with.
+	Args []string
+	// Stage is the position of the command in the Pipe it ran in, counting
+	// from 1, or 0 if it did not run in a Pipe.  In nested Pipes, it is the
+	// position in the innermost one.
+	Stage int
+	// Stderr is the end of what the command wrote to stderr.  It is empty if
+	// the command's stderr was shared with its stdout, as it is by Run and
+	// CombinedOutput, whose output already includes it, or went straight to
+	// a file, which the message then names, or to the writer given to
+	// WithStderr, which the message then says.
+	Stderr string
+	// Err is the error returned by os/exec.
+	Err *exec.ExitError
+
+	// redact hides the secrets given to WithRedact in the message.
+	redact redactor
+	// stderrFile is the name of the file stderr went straight to, if any.
+	stderrFile string
+	// stderrRedirected is whether stderr went to the writer given to
+	// WithStderr.
+	stderrRedirected bool
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("sh: %q", e.commandLine())
+	if e.Stage > 0 {
+		msg += fmt.Sprintf(" (stage %d)", e.Stage)
+	}
+	if code := e.ExitCode(); code >= 0 {
+		msg += fmt.Sprintf(" exited %d", code)
+	} else {
+		msg += " " + e.Err.Error()
+	}
+	if stderr := strings.TrimSpace(e.redact.string(e.Stderr)); stderr != "" {
+		msg += ": " + stderr
+	} else if e.stderrFile != "" {
+		msg += fmt.Sprintf(" (stderr went to %s)", e.stderrFile)
+	} else if e.stderrRedirected {
+		msg += " (stderr was redirected)"
+	}
+	return msg
+}
+
+// commandLine returns the command line of the command, with secrets hidden.
+func (e *ExitError) commandLine() string {
+	return commandLine(e.redact.string(e.Name), e.redact.args(e.Args))
+}
+
+// ExitCode returns the exit code of the command, or -1 if it was terminated by
+// a signal.
+func (e *ExitError) ExitCode() int {
+	return e.Err.ExitCode()
+}
+
+// Unwrap returns the underlying *exec.ExitError.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// StderrError is the error returned when a command run with FailOnStderr
+// writes to stderr, even though it succeeds.  Its message names the command
+// line and the stage, as that of ExitError does, and what the command wrote:
+//
+//	sh: "go vet ./..." (stage 1) wrote to stderr: vet: main.go:3:2: ...
+type StderrError struct {
+	// Name is the name of the command.
+	Name string
+	// Args are the arguments the command was run with.
+	Args []string
+	// Stage is the position of the command in the Pipe it ran in, as for
+	// ExitError.
+	Stage int
+	// Stderr is the end of what the command wrote to stderr.
+	Stderr string
+
+	// redact hides the secrets given to WithRedact in the message.
+	redact redactor
+}
+
+func (e *StderrError) Error() string {
+	msg := fmt.Sprintf("sh: %q", commandLine(e.redact.string(e.Name), e.redact.args(e.Args)))
+	if e.Stage > 0 {
+		msg += fmt.Sprintf(" (stage %d)", e.Stage)
+	}
+	return msg + " wrote to stderr: " + strings.TrimSpace(e.redact.string(e.Stderr))
+}
+
+// FailOnStderr returns an Executable that fails if any command of c writes
+// anything to stderr, even if it exits 0, for strict runs, such as in CI,
+// where a warning must not go unnoticed.  The error is then a *StderrError
+// holding what was written.  Stderr still goes where it would otherwise go.
+// Many commands write progress or other harmless messages to stderr, so this
+// is best kept to the commands that need it.  It has no effect with WithPTY,
+// whose terminal merges stderr into stdout.
+func (c Executable) FailOnStderr() Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.failOnStderr = true
+	})}
+}
+
+// WithTrace returns an Executable that writes the command line of each
+// command in c to w just before the command starts, like set -x in the shell.
+// Each line starts with "+ ", and arguments are quoted as by Quote, so that
+// the line can be pasted into a shell.  Every stage of a Pipe is traced,
+// including any arguments baked in with Cmd.
+func (c Executable) WithTrace(w io.Writer) Executable {
+	return c.WithTraceFormat(w, func(name string, args []string) string {
+		return "+ " + commandLine(name, args)
+	})
+}
+
+// WithTraceFormat works like WithTrace, but each line written to w is what
+// format returns for the command's name and arguments, followed by a newline,
+// to match the format of a log or to show less:
+//
+//	c.WithTraceFormat(os.Stderr, func(name string, args []string) string {
+//		return "$ " + name
+//	})
+//
+// Secrets given to WithRedact are hidden before format is called.  format may
+// be called from several goroutines at once.
+func (c Executable) WithTraceFormat(w io.Writer, format func(name string, args []string) string) Executable {
+	var mu sync.Mutex
+	return Executable{withHooks(c.Pipe, func(cmd *exec.Cmd, rd redactor) func() {
+		line := format(rd.string(cmd.Args[0]), rd.args(cmd.Args[1:]))
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(w, line)
+		return nil
+	})}
+}
+
+// Configure returns an Executable that calls fn with the exec.Cmd of each
+// command in c just before the command starts.  fn may change the command as
+// it likes, for instance to set SysProcAttr or ExtraFiles, which this package
+// has no options for.  fn is called once for every exec stage of a Pipe, and
+// may be called from several goroutines at once.
+func (c Executable) Configure(fn func(cmd *exec.Cmd)) Executable {
+	return Executable{withHooks(c.Pipe, func(cmd *exec.Cmd, _ redactor) func() {
+		fn(cmd)
+		return nil
+	})}
+}
+
+// WithCancelSignal returns an Executable that stops the commands of c by
+// sending them sig, rather than killing them outright, when they have to be
+// stopped early: when the context passed to RunContext is done, a timeout
+// passes, or a Process is killed.  A command that has not exited grace after
+// being sent sig is killed then; with a grace of 0, commands are killed at
+// once, without being sent sig.  This gives commands such as servers and
+// databases a chance to shut down cleanly, typically with sig set to
+// syscall.SIGTERM.  On Windows, which can only kill processes, commands are
+// killed once grace has passed.
+func (c Executable) WithCancelSignal(sig os.Signal, grace time.Duration) Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.stop.sig = sig
+		st.stop.grace = grace
+	})}
+}
+
+// WithProcessGroup returns an Executable that, if on is true, starts each
+// command of c in a process group of its own, and stops the whole group when
+// the command has to be stopped early, rather than just the command.  This
+// stops any processes the command started in turn, such as the background
+// jobs of a shell script, which would otherwise be left running, and could
+// keep the command's output open.  It works with WithCancelSignal, which then
+// signals the whole group.  Process groups are a Unix feature; on Windows,
+// WithProcessGroup has no effect.
+func (c Executable) WithProcessGroup(on bool) Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.stop.group = on
+	})}
+}
+
+// WithCredential returns an Executable that runs each command of c as the
+// user uid and the group gid, with groups as its supplementary groups, such
+// as to drop the privileges of a service running as root for a command that
+// does not need them.  With no groups, the commands have no supplementary
+// groups, rather than keeping those of the current process.  Changing user
+// needs the privileges to do so, as root has; without them, the commands fail
+// to start.  Users and groups are a Unix feature; on Windows, commands run
+// with WithCredential fail without being started.
+func (c Executable) WithCredential(uid, gid uint32, groups ...uint32) Executable {
+	groups = append([]uint32{}, groups...)
+	return Executable{withHooks(c.Pipe, func(cmd *exec.Cmd, _ redactor) func() {
+		if err := setCredential(cmd, uid, gid, groups); err != nil && cmd.Err == nil {
+			cmd.Err = err
+		}
+		return nil
+	})}
+}
+
+// WithNice returns an Executable that runs each command of c with its nice
+// value set to priority, from -20, the most favored, to 19, the least, so
+// that a CPU-heavy batch job can yield to interactive work.  The value is set
+// just after the command has started, so processes it starts straight away,
+// as a shell script may, can still get the value it inherited, rather than
+// priority.  Only privileged users can set a value lower than the current
+// one; if the value can't be set, the command runs at the value it inherited.
+// Nice values are a Unix feature; on Windows, WithNice has no effect.
+func (c Executable) WithNice(priority int) Executable {
+	return Executable{withHooks(c.Pipe, func(cmd *exec.Cmd, _ redactor) func() {
+		return func() { setNice(cmd, priority) }
+	})}
+}
+
+// WithRlimit returns an Executable that limits how much of resource each
+// command of c may use, as setrlimit does, so that a runaway command can't
+// take over the machine.  resource is one of the RLIMIT constants of package
+// syscall, such as:
+//
+//   - syscall.RLIMIT_AS: the bytes of memory the command may map.  Past it,
+//     allocating memory fails, and most commands then exit with an error of
+//     their own.
+//   - syscall.RLIMIT_CPU: the seconds of CPU time the command may use.  Past
+//     soft, it is sent SIGXCPU, which kills it unless it handles the signal,
+//     and past hard, it is killed.
+//   - syscall.RLIMIT_FSIZE: the bytes the command may write to a file.  Past
+//     it, the command is sent SIGXFSZ, which kills it unless it handles the
+//     signal.
+//   - syscall.RLIMIT_NOFILE: the number of files the command may have open.
+//
+// soft is the limit the command is held to, and hard is as far as the
+// command may raise it itself; math.MaxUint64 means no limit.  A command
+// killed by SIGXCPU or SIGXFSZ fails with an *RlimitError; one whose child
+// process is killed, as a shell script's may be, fails as the command itself
+// chooses to, and one killed at its hard CPU time limit fails with an
+// ExitError.  A command can't be given a hard limit higher than its current
+// one without privileges, and fails if its limits can't be set.
+//
+// The limits are set before the command runs, by running it with the prlimit
+// command of util-linux, so WithRlimit needs prlimit to be installed, which
+// minimal images, such as those based on busybox, often leave out.  Without
+// it, commands run with WithRlimit fail without being started, as they do
+// anywhere but Linux, and when they are run by a CommandRunner other than
+// ExecRunner.
+func (c Executable) WithRlimit(resource int, soft, hard uint64) Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.rlimits = append(st.rlimits[:len(st.rlimits):len(st.rlimits)], rlimit{resource: resource, soft: soft, hard: hard})
+	})}
+}
+
+// rlimit is a resource limit set with WithRlimit.
+type rlimit struct {
+	resource   int
+	soft, hard uint64
+}
+
+// RlimitError is the error returned when a command run with WithRlimit is
+// killed for going past one of its limits.  Its message is that of the
+// ExitError, followed by the limit.
+type RlimitError struct {
+	// Resource is the resource the command used too much of, such as
+	// syscall.RLIMIT_CPU.
+	Resource int
+	// Limit is the soft limit the command went past.
+	Limit uint64
+	// Err is the error of the command.
+	Err *ExitError
+}
+
+func (e *RlimitError) Error() string {
+	return fmt.Sprintf("%v (over its %s limit of %d)", e.Err, rlimitName(e.Resource), e.Limit)
+}
+
+// Unwrap returns the *ExitError of the command.
+func (e *RlimitError) Unwrap() error {
+	return e.Err
+}
+
+// WithPTY returns an Executable that runs each command of c in a
+// pseudo-terminal of its own, for commands that act differently, or refuse to
+// run, when they are not talking to a terminal, such as those that only use
+// color or show progress bars on one.  The terminal is each command's
+// controlling terminal, and its stdin, stdout and stderr: what the command
+// is given on stdin is typed into the terminal, followed by Ctrl-D, and what
+// the terminal shows is the command's stdout.  So the output is as a terminal
+// shows it, with \r\n line endings, and with the input echoed unless the
+// command turns echoing off, as password prompts do; stderr is merged into
+// stdout.  The terminal is 80 columns by 24 rows, and is never resized.
+// Pseudo-terminals are only supported on Linux; elsewhere, commands run with
+// WithPTY fail without being started.
+func (c Executable) WithPTY() Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.pty = true
+	})}
+}
+
+// stopSettings say how to stop a command early.
+type stopSettings struct {
+	// sig is sent to stop the command, or, if nil, it is killed.
+	sig os.Signal
+	// grace is how long to wait after sending sig before killing it.
+	grace time.Duration
+	// group, if true, runs the command in a process group of its own, and
+	// stops the whole group.
+	group bool
+}
+
+// apply sets up cmd to be stopped as ss says.
+func (ss stopSettings) apply(cmd *exec.Cmd) {
+	if ss == (stopSettings{}) {
+		return
+	}
+	sig := ss.sig
+	if sig == nil || ss.grace <= 0 {
+		// With no time to exit after sig, the command is killed at once.
+		sig = os.Kill
+	}
+	if ss.group {
+		setProcessGroup(cmd)
+	}
+	cmd.Cancel = func() error {
+		return signalCmd(cmd, sig, ss.group)
+	}
+	cmd.WaitDelay = ss.grace
+}
+
+// WithRedact returns an Executable that shows each of secrets as *** wherever
+// the commands of c are shown: in the lines written by WithTrace, in the
+// records logged by WithLogger, in dry runs, and in the messages of their
+// errors, including the stderr they hold.  This keeps passwords and tokens
+// passed as arguments, as to curl -u user:pass, out of logs.  The secrets are
+// still passed to the commands, and the fields of an ExitError, such as Args,
+// are left as they are.  Empty secrets are ignored.
+func (c Executable) WithRedact(secrets ...string) Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.redact = append(st.redact[:len(st.redact):len(st.redact)], secrets...)
+	})}
+}
+
+// redactor hides secrets in text about commands.
+type redactor []string
+
+// string returns s with every secret in it replaced by ***.
+func (rd redactor) string(s string) string {
+	for _, secret := range rd {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "***")
+		}
+	}
+	return s
+}
+
+// args returns a copy of args with their secrets hidden.
+func (rd redactor) args(args []string) []string {
+	if len(rd) == 0 {
+		return args
+	}
+	hidden := make([]string, len(args))
+	for i, arg := range args {
+		hidden[i] = rd.string(arg)
+	}
+	return hidden
+}
+
+// AcceptExitCodes returns an Executable that treats the commands in c exiting
+// with any of the given codes as success, as well as exiting 0.  This suits
+// commands like grep and diff, which exit 1 to give an answer rather than to
+// report a failure.  Any other non-zero exit is still an *ExitError.  In a
+// Pipe, the codes apply to each stage on its own.
+func (c Executable) AcceptExitCodes(codes ...int) Executable {
+	return Executable{withSettings(c.Pipe, func(st *settings) {
+		st.accept = append(st.accept[:len(st.accept):len(st.accept)], codes...)
+	})}
+}
+
+// commandLine formats a command and its arguments the way they would be typed
+// into a shell.
+func commandLine(name string, args []string) string {
+	return QuoteAll(append([]string{name}, args...)...)
+}
+
+// execPipe returns a pipe.Pipe that runs the named command, like pipe.Exec,
+// but whose errors can be inspected with errors.As.  Killing the pipe kills
+// the process and waits for it to exit.
+func execPipe(name string, args ...string) pipe.Pipe {
+	return func(s *pipe.State) error {
+		st := settingsOf(s)
+		if st.commands != nil {
+			*st.commands = append(*st.commands, plannedCmd{name: name, args: args, dir: s.Dir, env: s.Env, redact: st.redact})
+			return nil
+		}
+		hooks := st.hooks
+		accept := st.accept
+		stage := st.stage
+		stop := st.stop
+		timings := st.timings
+		runner := runnerOf(st)
+		log := loggerOf(st)
+		redact := st.redact
+		usePTY := st.pty
+		failOnStderr := st.failOnStderr
+		rlimits := st.rlimits
+		return addTask(s, func(ctx context.Context, s *pipe.State) (err error) {
+			cmd := exec.CommandContext(ctx, name, args...)
+			if path, ok := pathOf(s.Env); ok && path != os.Getenv("PATH") {
+				cmd.Path, cmd.Err = lookPath(name, path, s.Dir)
+			}
+			cmd.Dir = s.Dir
+			cmd.Env = s.Env
+			cmd.Stdin = s.Stdin
+			cmd.Stdout = s.Stdout
+			cmd.Stderr = s.Stderr
+			redirected, isRedirected := s.Stderr.(*redirectedStderr)
+			if isRedirected {
+				cmd.Stderr = redirected.w
+			}
+			stop.apply(cmd)
+			var stderr *tailBuffer
+			stderrFile, isFile := cmd.Stderr.(*os.File)
+			if !isFile && !isRedirected && !sameWriter(s.Stderr, s.Stdout) {
+				stderr = &tailBuffer{}
+				cmd.Stderr = io.MultiWriter(s.Stderr, stderr)
+			}
+			// FailOnStderr needs to see stderr even where ExitError leaves
+			// it out.
+			written := stderr
+			if failOnStderr && written == nil {
+				written = &tailBuffer{}
+				cmd.Stderr = io.MultiWriter(cmd.Stderr, written)
+			}
+			fail := func(err error) error {
+				err = cmdError(name, args, accept, err)
+				if err == nil && failOnStderr && written.String() != "" {
+					return &StderrError{Name: name, Args: args, Stage: stage, Stderr: written.String(), redact: redact}
+				}
+				var exitErr *ExitError
+				if errors.As(err, &exitErr) {
+					exitErr.Stage = stage
+					exitErr.Stderr = stderr.String()
+					exitErr.redact = redact
+					switch {
+					case usePTY:
+					case isFile && stderrFile != s.Stdout:
+						exitErr.stderrFile = stderrFile.Name()
+					case isRedirected:
+						exitErr.stderrRedirected = true
+					}
+					if lim, ok := rlimitHit(exitErr, rlimits); ok && ctx.Err() == nil {
+						return &RlimitError{Resource: lim.resource, Limit: lim.soft, Err: exitErr}
+					}
+				}
+				return err
+			}
+			var started []func()
+			for _, h := range hooks {
+				if f := h(cmd, redact); f != nil {
+					started = append(started, f)
+				}
+			}
+			if timings != nil {
+				start := time.Now()
+				defer func() {
+					timings.add(CommandTiming{Name: name, Args: args, Stage: stage, Start: start, End: time.Now()})
+				}()
+			}
+			if log != nil {
+				start := time.Now()
+				defer func() {
+					log.log(ctx, cmd, redact, name, args, stage, time.Since(start), err, stderr)
+				}()
+			}
+			if !isExecRunner(runner) {
+				if len(rlimits) > 0 {
+					return errors.New("sh: WithRlimit can't be used with a CommandRunner other than ExecRunner")
+				}
+				return fail(runner.Run(ctx, cmd))
+			}
+			var term *terminal
+			if usePTY {
+				if term, err = openTerminal(cmd); err != nil {
+					return err
+				}
+				defer term.close()
+			}
+			if err = checkDir(cmd); err != nil {
+				return err
+			}
+			out, err := pipeOutput(cmd)
+			if err != nil {
+				return err
+			}
+			err = startLimited(cmd, rlimits)
+			out.started()
+			if err != nil {
+				return startError(err)
+			}
+			if term != nil {
+				term.started()
+			}
+			for _, f := range started {
+				f()
+			}
+			err = cmd.Wait()
+			if outErr := out.wait(ctx); err == nil {
+				err = outErr
+			}
+			return fail(err)
+		})
+	}
+}
+
+// cmdError returns the error for the named command having failed with err, or
+// nil if err is nil, the command exited with one of the accept codes, or it
+// was stopped by a broken pipe.
+func cmdError(name string, args []string, accept []int, err error) error {
+	if brokenPipe(err) {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		for _, code := range accept {
+			if exitErr.ExitCode() == code {
+				return nil
+			}
+		}
+		return &ExitError{Name: name, Args: args, Err: exitErr}
+	}
+	if err != nil {
+		return fmt.Errorf("command %q: %w", name, startError(err))
+	}
+	return nil
+}
+
+// checkDir returns an error if the directory cmd is to run in doesn't exist.
+// os.StartProcess checks this itself only when cmd has no SysProcAttr; with
+// one, a missing directory fails the exec instead, which would look just like
+// a missing executable to startError.
+func checkDir(cmd *exec.Cmd) error {
+	if cmd.Dir == "" || cmd.Err != nil {
+		return nil
+	}
+	if _, err := os.Stat(cmd.Dir); err != nil {
+		pathErr := err.(*fs.PathError)
+		pathErr.Op = "chdir"
+		return pathErr
+	}
+	return nil
+}
+
+// startError returns err, the error from starting a command, made to match
+// ErrNotFound if the command's executable doesn't exist.
+func startError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "fork/exec" && errors.Is(err, fs.ErrNotExist) {
+		return &notFoundError{err}
+	}
+	return err
+}
+
+// sameWriter reports whether a and b are the same writer.  Writers that can't
+// be compared are taken to be different.
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// output copies the stdout and stderr of a command to writers that aren't
+// files, as os/exec does, but stops once the command has been killed, rather
+// than waiting for processes it started, which may still hold its output open
+// and run for much longer, to exit too.
+type output struct {
+	// writers are the command's ends of the pipes, and readers are ours.
+	writers, readers []*os.File
+	copied           chan error
+}
+
+// killedOutputDelay is how long output is still copied after a command has
+// been killed, for what it wrote before it was.
+const killedOutputDelay = 100 * time.Millisecond
+
+// pipeOutput sets up the output of cmd to be copied by an output.
+func pipeOutput(cmd *exec.Cmd) (*output, error) {
+	o := &output{copied: make(chan error, 2)}
+	stdout := cmd.Stdout
+	var err error
+	if cmd.Stdout, err = o.pipe(stdout); err != nil {
+		o.started()
+		return nil, err
+	}
+	if sameWriter(cmd.Stderr, stdout) {
+		cmd.Stderr = cmd.Stdout
+	} else if cmd.Stderr, err = o.pipe(cmd.Stderr); err != nil {
+		o.started()
+		return nil, err
+	}
+	return o, nil
+}
+
+// pipe returns the writer for a command to write to in place of w, which is
+// a pipe that is copied to w, unless w is nil or a file.
+func (o *output) pipe(w io.Writer) (io.Writer, error) {
+	if _, ok := w.(*os.File); ok || w == nil {
+		return w, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return w, err
+	}
+	o.writers = append(o.writers, pw)
+	o.readers = append(o.readers, pr)
+	go func() {
+		_, err := io.Copy(w, pr)
+		// The copy may have stopped on an error writing to w.
+		pr.Close()
+		o.copied <- err
+	}()
+	return pw, nil
+}
+
+// started closes the command's ends of the pipes, once it has them, or has
+// failed to start.
+func (o *output) started() {
+	for _, w := range o.writers {
+		w.Close()
+	}
+}
+
+// wait waits for the copies to finish, and returns the first error from
+// one.  Once ctx is done, the copies are stopped after killedOutputDelay.
+func (o *output) wait(ctx context.Context) error {
+	var stop <-chan time.Time
+	stopped := false
+	var err error
+	for range o.readers {
+		var copyErr error
+	copying:
+		for {
+			select {
+			case copyErr = <-o.copied:
+				break copying
+			case <-ctx.Done():
+				stop = time.After(killedOutputDelay)
+				ctx = context.Background()
+			case <-stop:
+				stopped = true
+				for _, r := range o.readers {
+					r.Close()
+				}
+			}
+		}
+		if err == nil && !stopped {
+			err = copyErr
+		}
+	}
+	return err
+}
+
+// tailBuffer is an io.Writer that keeps the last bytes written to it.
+type tailBuffer struct {
+	buf []byte
+}
+
+// maxTail is the most that a tailBuffer keeps.
+const maxTail = 1024
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > maxTail {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-maxTail:]...)
+	}
+	return len(p), nil
+}
+
+// String returns what b has kept, or "" if b is nil.
+func (b *tailBuffer) String() string {
+	if b == nil {
+		return ""
+	}
+	return string(b.buf)
+}
+
+// cmdHook is called with each exec.Cmd of a pipe just before it is started,
+// and the redactor that hides its secrets.  If it returns a function, that is
+// called once the command has started.
+type cmdHook func(cmd *exec.Cmd, rd redactor) (started func())
+
+// settings are the settings of a pipe.State that pipe.State has no field for.
+type settings struct {
+	// hooks are called with every exec.Cmd created for the state.
+	hooks []cmdHook
+	// commands, if not nil, makes the setup a dry run: instead of adding a
+	// task, exec stages append the command they would run to it.
+	commands *[]plannedCmd
+	// accept are the non-zero exit codes that count as success.
+	accept []int
+	// stage is the position of the state's commands in their Pipe, counting
+	// from 1, or 0 outside of a Pipe.
+	stage int
+	// stop says how to stop the state's commands early.
+	stop stopSettings
+	// timings, if not nil, records how long each command takes.
+	timings *timings
+	// runner, if not nil, runs the commands of the state in place of
+	// DefaultRunner.
+	runner CommandRunner
+	// logger, if not nil, logs the commands of the state in place of
+	// DefaultLogger.
+	logger *logger
+	// redact are the secrets to hide when showing the state's commands.
+	redact redactor
+	// pty runs the state's commands in pseudo-terminals.
+	pty bool
+	// failOnStderr fails the state's commands if they write to stderr.
+	failOnStderr bool
+	// rlimits are the resource limits of the state's commands.
+	rlimits []rlimit
+}
+
+// plannedCmd is a command that a dry run found would be run.
+type plannedCmd struct {
+	name   string
+	args   []string
+	dir    string
+	env    []string
+	redact redactor
+}
+
+// setups holds the settings of each pipe.State whose pipe is being set up.  A
+// pipe is set up start to finish on one goroutine, so the settings are kept
+// here, keyed by state, for just as long as the setup lasts.
+var setups = struct {
+	sync.Mutex
+	m map[*pipe.State]settings
+}{m: make(map[*pipe.State]settings)}
+
+// withSettings returns a pipe.Pipe that sets up p with the settings of the
+// state changed by f.  The settings are restored once p has been set up, and
+// the state's entry in setups is removed if it had none before.
+func withSettings(p pipe.Pipe, f func(st *settings)) pipe.Pipe {
+	return func(s *pipe.State) error {
+		old, had := lookupSettings(s)
+		st := old
+		f(&st)
+		setSettings(s, st)
+		defer func() {
+			if had {
+				setSettings(s, old)
+			} else {
+				clearSettings(s)
+			}
+		}()
+		return p(s)
+	}
+}
+
+// withHooks returns a pipe.Pipe that sets up p with hooks added to the hooks
+// of every exec.Cmd it creates.
+func withHooks(p pipe.Pipe, hooks ...cmdHook) pipe.Pipe {
+	return withSettings(p, func(st *settings) {
+		st.hooks = append(st.hooks[:len(st.hooks):len(st.hooks)], hooks...)
+	})
+}
+
+func settingsOf(s *pipe.State) settings {
+	st, _ := lookupSettings(s)
+	return st
+}
+
+// lookupSettings returns the settings of s, and whether it has any.
+func lookupSettings(s *pipe.State) (settings, bool) {
+	setups.Lock()
+	defer setups.Unlock()
+	st, ok := setups.m[s]
+	return st, ok
+}
+
+func setSettings(s *pipe.State, st settings) {
+	setups.Lock()
+	defer setups.Unlock()
+	setups.m[s] = st
+}
+
+func clearSettings(s *pipe.State) {
+	setups.Lock()
+	defer setups.Unlock()
+	delete(setups.m, s)
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package sh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// brokenPipe reports whether err comes from writing to a pipe that nothing
+// reads any more, because the stage after it stopped reading early, as Head
+// does.  A command killed by SIGPIPE counts, as does a write failing with
+// EPIPE.
+func brokenPipe(err error) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		ws, ok := exitErr.Sys().(syscall.WaitStatus)
+		return ok && ws.Signaled() && ws.Signal() == syscall.SIGPIPE
+	}
+	return errors.Is(err, syscall.EPIPE)
+}
+
+// setProcessGroup makes cmd start in a process group of its own.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalCmd sends sig to the started command cmd, or if group is true, to
+// its whole process group.
+func signalCmd(cmd *exec.Cmd, sig os.Signal, group bool) error {
+	if !group {
+		return cmd.Process.Signal(sig)
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-cmd.Process.Pid, s)
+}
+
+// setCredential makes cmd run as the user uid and the group gid, with groups
+// as its supplementary groups.
+func setCredential(cmd *exec.Cmd, uid, gid uint32, groups []uint32) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: groups}
+	return nil
+}
+
+// setNice sets the nice value of the started command cmd, if it can.
+func setNice(cmd *exec.Cmd, priority int) {
+	syscall.Setpriority(syscall.PRIO_PROCESS, cmd.Process.Pid, priority)
+}
@@ -0,0 +1,158 @@
+//go:build !windows
+
+package sh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func TestWithCancelSignal(t *testing.T) {
+	server := sh.Shell(`trap 'echo cleaned up; exit 0' TERM; echo ready; while :; do sleep 0.05; done`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	out, err := server.WithCancelSignal(syscall.SIGTERM, 5*time.Second).RunContext(ctx, "")
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !strings.Contains(out, "cleaned up") {
+		t.Errorf("got output %q, want the trap to have run", out)
+	}
+}
+
+func TestWithCancelSignalGrace(t *testing.T) {
+	stubborn := sh.Shell(`trap '' TERM; while :; do sleep 0.05; done`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	stubborn.WithCancelSignal(syscall.SIGTERM, 200*time.Millisecond).RunContext(ctx, "")
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %v to kill a command ignoring the signal", d)
+	}
+}
+
+func TestWithCancelSignalNoGrace(t *testing.T) {
+	stubborn := sh.Shell(`trap '' TERM; exec sleep 5`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	stubborn.WithCancelSignal(syscall.SIGTERM, 0).RunContext(ctx, "")
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("took %v to kill a command ignoring the signal", d)
+	}
+}
+
+func TestRunContextChildHoldsOutput(t *testing.T) {
+	// The sleep outlives the shell it is killed with, and holds its output
+	// open, but isn't waited for.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	out, err := sh.Shell("echo hi; sleep 5").RunContext(ctx, "")
+	if err != context.DeadlineExceeded || out != "hi\n" {
+		t.Errorf("got %q, %v, want %q, %v", out, err, "hi\n", context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("took %v to return once the context was done", d)
+	}
+
+	// One that isn't killed is still waited for.
+	out, err = sh.Shell("echo a; (sleep 0.3; echo b) &").Run()
+	if err != nil || out != "a\nb\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "a\nb\n")
+	}
+}
+
+func TestWithTimeoutChildHoldsOutput(t *testing.T) {
+	start := time.Now()
+	out, err := sh.WithTimeout(200*time.Millisecond, sh.Shell("echo started; sleep 10")).Run()
+	if _, ok := err.(*sh.TimeoutError); !ok || out != "started\n" {
+		t.Errorf("got %q, %v, want %q and a *sh.TimeoutError", out, err, "started\n")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("took %v to time out", d)
+	}
+}
+
+func TestFirstLineProcessGroup(t *testing.T) {
+	// Commands read from stay in the group of this process, so that signals
+	// from the terminal still reach them.
+	got, err := sh.Shell("ps -o pgid= -p $$").FirstLine("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(syscall.Getpgrp()); strings.TrimSpace(got) != want {
+		t.Errorf("got process group %s, want %s", strings.TrimSpace(got), want)
+	}
+}
+
+func TestWithProcessGroup(t *testing.T) {
+	// Without a process group, the background job would survive the shell
+	// and go on to create the file.
+	file := filepath.Join(t.TempDir(), "survived")
+	script := sh.Shell("(sleep 0.5; touch " + sh.Quote(file) + ") & echo started; wait")
+
+	out, err := sh.WithTimeout(200*time.Millisecond, script.WithProcessGroup(true)).Run()
+	if _, ok := err.(*sh.TimeoutError); !ok {
+		t.Errorf("got error %v, want a *sh.TimeoutError", err)
+	}
+	if out != "started\n" {
+		t.Errorf("got output %q, want %q", out, "started\n")
+	}
+	time.Sleep(time.Second)
+	if _, err := os.Stat(file); err == nil {
+		t.Error("the background job was not killed")
+	}
+}
+
+func TestWithCredential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing user needs root")
+	}
+	id := sh.Cmd("id")
+
+	out, err := id("-u").WithCredential(65534, 65534).Output()
+	if err != nil || out != "65534\n" {
+		t.Errorf("got user %q, %v, want %q", out, err, "65534\n")
+	}
+	out, err = id("-G").WithCredential(65534, 65534).Output()
+	if err != nil || out != "65534\n" {
+		t.Errorf("got groups %q, %v, want only the group given", out, err)
+	}
+	out, err = id("-G").WithCredential(65534, 65534, 1, 2).Output()
+	if groups := strings.Fields(out); err != nil || len(groups) != 3 || groups[0] != "65534" {
+		t.Errorf("got groups %q, %v, want the group and 2 supplementary groups", out, err)
+	}
+}
+
+func TestWithNice(t *testing.T) {
+	p, err := sh.Cmd("sleep")("10").WithNice(7).Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Wait()
+	defer p.Kill()
+	// The value is set just after the command has started, so it may take
+	// a moment to show.
+	ps := sh.Cmd("ps")("-o", "nice=", "-p", strconv.Itoa(p.Pid()))
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		out, err := ps.Output()
+		if err == nil && strings.TrimSpace(out) == "7" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, %v, want a nice value of 7", out, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package sh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// errorNoData is the error for writing to a pipe whose other end is closed.
+const errorNoData syscall.Errno = 232
+
+// brokenPipe reports whether err comes from writing to a pipe that nothing
+// reads any more, because the stage after it stopped reading early, as Head
+// does.  Windows has no SIGPIPE, so a command that fails that way exits with
+// an error of its own, which is not recognized here.
+func brokenPipe(err error) bool {
+	return errors.Is(err, errorNoData) || errors.Is(err, syscall.ERROR_BROKEN_PIPE)
+}
+
+// setProcessGroup does nothing, since Windows has no process groups to stop
+// together.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalCmd sends sig to the started command cmd.  On Windows, only os.Kill
+// can be sent.  group is ignored.
+func signalCmd(cmd *exec.Cmd, sig os.Signal, group bool) error {
+	return cmd.Process.Signal(sig)
+}
+
+// setCredential fails, since Windows has no Unix users and groups to run cmd
+// as.
+func setCredential(cmd *exec.Cmd, uid, gid uint32, groups []uint32) error {
+	return errors.New("sh: WithCredential is not supported on windows")
+}
+
+// setNice does nothing, since Windows has no nice values.
+func setNice(cmd *exec.Cmd, priority int) {}
@@ -0,0 +1,59 @@
+package sh
+
+import (
+	"io"
+	"net/http"
+)
+
+// Handler returns an http.Handler that runs the Executable that build returns
+// for each request, with the request body as its stdin, and streams its
+// stdout as the response body, flushing each write so that the client sees
+// output as it is produced, such as to follow a log:
+//
+//	http.Handle("/log", sh.Handler(func(r *http.Request) sh.Executable {
+//		return tail("-f", "/var/log/app.log")
+//	}))
+//
+// The response is plain text.  If the Executable fails before it writes
+// anything, the response is a 500 Internal Server Error, whose body is the
+// error, which for a command that exited with an error includes the end of
+// its stderr, as ExitError does.  If it fails after output has been sent, the
+// status can no longer be changed, so the response is cut off instead, and
+// the client sees it end early.  Since errors name the commands that failed,
+// WithRedact should hide any secrets in them.  The Executable is run with
+// the request's context, so that it is stopped if the client goes away.
+func Handler(build func(*http.Request) Executable) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fw := &flushWriter{w: w, rc: http.NewResponseController(w)}
+		err := build(r).runTo(r.Context(), r.Body, fw, io.Discard)
+		switch {
+		case err == nil, r.Context().Err() != nil:
+		case fw.wrote:
+			panic(http.ErrAbortHandler)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, err.Error()+"\n")
+		}
+	})
+}
+
+// flushWriter writes to an http.ResponseWriter, flushing after every write.
+type flushWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	wrote bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	fw.wrote = true
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	fw.rc.Flush()
+	return n, nil
+}
@@ -0,0 +1,134 @@
+package sh_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleHandler() {
+	grep := sh.Cmd("grep")
+
+	// Search the crawl for the word given in the query.
+	h := sh.Handler(func(r *http.Request) sh.Executable {
+		return sh.PipeWith(SWCrawl, grep("-i", "--", r.URL.Query().Get("q")))
+	})
+
+	for _, q := range []string{"evil", "wookiee"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/?q="+q, nil))
+		fmt.Print(rec.Code, " ", rec.Body)
+	}
+	// output:
+	// 200 against the evil Galactic Empire.
+	// 500 sh: "grep -i -- wookiee" (stage 1) exited 1
+}
+
+func TestHandler(t *testing.T) {
+	h := sh.Handler(func(r *http.Request) sh.Executable {
+		switch r.URL.Path {
+		case "/upper":
+			return sh.ToUpper()
+		case "/fail":
+			return sh.Shell("echo no such thing >&2; exit 2")
+		case "/late":
+			return sh.Shell("echo partial; exit 2")
+		}
+		return sh.Cmd("sleep")("10")
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// The request body is stdin.
+	resp, err := http.Post(srv.URL+"/upper", "text/plain", strings.NewReader("hi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != 200 || string(body) != "HI\n" {
+		t.Errorf("got %d %q, %v, want 200 %q", resp.StatusCode, body, err, "HI\n")
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("got content type %q, want plain text", ct)
+	}
+
+	// A failure is a 500 with stderr in the body.
+	resp, err = http.Get(srv.URL + "/fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 500 || !strings.Contains(string(body), "exited 2: no such thing") {
+		t.Errorf("got %d %q, want a 500 with the stderr", resp.StatusCode, body)
+	}
+
+	// A failure after the output has started cuts the response off.
+	resp, err = http.Get(srv.URL + "/late")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "partial\n" || err == nil {
+		t.Errorf("got %d %q, %v, want 200 %q cut off with an error", resp.StatusCode, body, err, "partial\n")
+	}
+
+	// Going away stops the command.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/sleep", nil)
+	done := make(chan struct{})
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	})
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Error("request with a short deadline succeeded")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("the command was not stopped when the client went away")
+	}
+}
+
+func TestHandlerStreams(t *testing.T) {
+	// Each line reaches the client before the command ends.
+	h := sh.Handler(func(r *http.Request) sh.Executable {
+		return sh.Shell("echo first; sleep 10; echo second").WithProcessGroup(true)
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line := make([]byte, len("first\n"))
+	got := make(chan string, 1)
+	go func() {
+		n, _ := io.ReadFull(resp.Body, line)
+		got <- string(line[:n])
+	}()
+	select {
+	case s := <-got:
+		if s != "first\n" {
+			t.Errorf("got %q, want %q", s, "first\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("output was not flushed")
+	}
+}
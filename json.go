@@ -0,0 +1,88 @@
+package sh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSON runs cmd with the given string as standard input, and decodes its
+// standard output as JSON into a value of type T, as json.Unmarshal does.  If
+// the output is not valid JSON for T, the error quotes the start of the
+// output, to show what the command printed instead.  Standard error is
+// discarded.
+func JSON[T any](cmd Executable, stdin string) (T, error) {
+	var v T
+	out := &buffer{}
+	if err := cmd.runTo(context.Background(), strings.NewReader(stdin), out, io.Discard); err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+		return v, fmt.Errorf("decoding JSON from %q: %w", snippet(out.String()), err)
+	}
+	return v, nil
+}
+
+// snippet returns the start of s, cut short if s is long, for quoting it in
+// an error.
+func snippet(s string) string {
+	const max = 64
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// JSONLines runs cmd with the given string as standard input, and decodes
+// each line of its standard output as JSON into a value of type T, as soon as
+// the line is written, for commands that print one JSON object per line.  fn
+// is called with each value, in order, on the calling goroutine.  Blank lines
+// are skipped.  Standard error is discarded.
+//
+// A line that is not valid JSON for T does not stop the stream: fn is called
+// with the zero T and a *JSONLineError instead.  If fn returns an error, cmd
+// is killed and JSONLines returns that error, so to stop at the first bad
+// line, fn returns the error it was given.  Otherwise JSONLines returns once
+// cmd has finished, with its error if any.
+func JSONLines[T any](cmd Executable, stdin string, fn func(v T, err error) error) error {
+	r, err := cmd.reader(strings.NewReader(stdin))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n := 0
+	return forEachLine(r, func(line string, _ bool) error {
+		n++
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		var v T
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			var zero T
+			return fn(zero, &JSONLineError{Line: n, Text: line, Err: err})
+		}
+		return fn(v, nil)
+	})
+}
+
+// JSONLineError is the error passed to the fn of JSONLines for a line that
+// could not be decoded.
+type JSONLineError struct {
+	// Line is the number of the line, counting from 1.
+	Line int
+	// Text is the line, without its newline.
+	Text string
+	// Err is the error from decoding the line.
+	Err error
+}
+
+func (e *JSONLineError) Error() string {
+	return fmt.Sprintf("decoding JSON from line %d %q: %v", e.Line, snippet(e.Text), e.Err)
+}
+
+// Unwrap returns the error from decoding the line.
+func (e *JSONLineError) Unwrap() error {
+	return e.Err
+}
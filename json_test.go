@@ -0,0 +1,88 @@
+package sh_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleJSON() {
+	type release struct {
+		Name    string `json:"name"`
+		Version int    `json:"version"`
+	}
+
+	r, err := sh.JSON[release](sh.Echo(`{"name": "sh", "version": 2}`), "")
+	fmt.Printf("%+v %v\n", r, err)
+	// output:
+	// {Name:sh Version:2} <nil>
+}
+
+func TestJSONError(t *testing.T) {
+	out := "not json " + strings.Repeat("x", 100)
+	_, err := sh.JSON[map[string]int](sh.Echo(out), "")
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("got error %v, want a *json.SyntaxError", err)
+	}
+	if !strings.Contains(err.Error(), `"not json xxx`) {
+		t.Errorf("error %q does not quote the output", err)
+	}
+	if strings.Contains(err.Error(), strings.Repeat("x", 100)) {
+		t.Errorf("error %q quotes all of the output", err)
+	}
+
+	_, err = sh.JSON[int](sh.Shell("exit 4"), "")
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("got error %v, want an *sh.ExitError", err)
+	}
+}
+
+func ExampleJSONLines() {
+	type event struct {
+		Action string `json:"action"`
+	}
+	events := sh.Echo(`{"action": "start"}` + "\n" + `oops` + "\n" + `{"action": "stop"}`)
+
+	err := sh.JSONLines(events, "", func(e event, err error) error {
+		if err != nil {
+			fmt.Println("skipping:", err)
+			return nil
+		}
+		fmt.Println(e.Action)
+		return nil
+	})
+	fmt.Println(err)
+	// output:
+	// start
+	// skipping: decoding JSON from line 2 "oops": invalid character 'o' looking for beginning of value
+	// stop
+	// <nil>
+}
+
+func TestJSONLinesStop(t *testing.T) {
+	yes := sh.Cmd("yes")
+
+	// yes never stops on its own, so this only returns because fn stops it.
+	n := 0
+	err := sh.JSONLines(yes("1"), "", func(v int, err error) error {
+		if n++; n == 3 {
+			return errors.New("enough")
+		}
+		return err
+	})
+	if err == nil || err.Error() != "enough" {
+		t.Errorf("got error %v, want enough", err)
+	}
+
+	err = sh.JSONLines(yes("x"), "", func(v int, err error) error { return err })
+	var lineErr *sh.JSONLineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 1 || lineErr.Text != "x" {
+		t.Errorf("got error %v, want a *sh.JSONLineError for line 1", err)
+	}
+}
@@ -0,0 +1,119 @@
+package sh
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"time"
+)
+
+// DefaultLogger, if not nil, logs every command that is not run with a context
+// from WithLogger, as WithLogger does, at slog.LevelInfo and without stderr.
+// It should only be changed while no Executables are running, such as at the
+// start of main.
+var DefaultLogger *slog.Logger
+
+// WithLogger returns a copy of ctx that makes RunContext log each command it
+// runs to l, in place of DefaultLogger, once the command has finished.  Every
+// exec stage of a Pipe is logged on its own; stages written in Go, such as
+// Grep, are not.  Each record has the message "command" and these attributes:
+//
+//   - cmd: the name of the command
+//   - args: its arguments
+//   - stage: its position in its Pipe, or 0, as for ExitError
+//   - duration: how long it ran
+//   - exit_code: the code it exited with, or -1 if it was killed by a signal,
+//     left out if it never started
+//   - failed: whether it failed
+//   - error: its error, left out if it did not fail
+//
+// A nil l turns logging off.
+func WithLogger(ctx context.Context, l *slog.Logger, opts ...LogOption) context.Context {
+	lg := &logger{l: l, level: slog.LevelInfo}
+	for _, opt := range opts {
+		opt(lg)
+	}
+	return context.WithValue(ctx, loggerKey{}, lg)
+}
+
+// LogOption configures the logging set up by WithLogger.
+type LogOption func(*logger)
+
+// LogLevel sets the level commands are logged at, which is slog.LevelInfo by
+// default.
+func LogLevel(level slog.Level) LogOption {
+	return func(lg *logger) {
+		lg.level = level
+	}
+}
+
+// LogStderr adds a stderr attribute to each record, holding the end of what
+// the command wrote to stderr, as kept in an ExitError.  Like ExitError.Stderr,
+// it is empty if stderr was shared with stdout or went straight to a file.
+func LogStderr() LogOption {
+	return func(lg *logger) {
+		lg.stderr = true
+	}
+}
+
+// loggerKey is the context key for the logger set with WithLogger.
+type loggerKey struct{}
+
+// logger logs commands as they finish.
+type logger struct {
+	l      *slog.Logger
+	level  slog.Level
+	stderr bool
+}
+
+// loggerOf returns the logger for commands set up with st.
+func loggerOf(st settings) *logger {
+	if st.logger != nil {
+		return st.logger
+	}
+	if DefaultLogger != nil {
+		return &logger{l: DefaultLogger, level: slog.LevelInfo}
+	}
+	return nil
+}
+
+// log logs that cmd, the command name with args, finished after d with err,
+// hiding the secrets of rd.
+func (lg *logger) log(ctx context.Context, cmd *exec.Cmd, rd redactor, name string, args []string, stage int, d time.Duration, err error, stderr *tailBuffer) {
+	if lg == nil || lg.l == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("cmd", rd.string(name)),
+		slog.Any("args", rd.args(args)),
+		slog.Int("stage", stage),
+		slog.Duration("duration", d),
+	}
+	if code, ok := exitCode(cmd, err); ok {
+		attrs = append(attrs, slog.Int("exit_code", code))
+	}
+	attrs = append(attrs, slog.Bool("failed", err != nil))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", rd.string(err.Error())))
+	}
+	if lg.stderr {
+		attrs = append(attrs, slog.String("stderr", rd.string(stderr.String())))
+	}
+	lg.l.LogAttrs(ctx, lg.level, "command", attrs...)
+}
+
+// exitCode returns the code that cmd exited with, if it ran.
+func exitCode(cmd *exec.Cmd, err error) (int, bool) {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode(), true
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	if err == nil {
+		return 0, true
+	}
+	return 0, false
+}
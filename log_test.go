@@ -0,0 +1,82 @@
+package sh_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleWithLogger() {
+	// Drop the time and duration, which change from run to run.
+	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	ctx := sh.WithLogger(context.Background(), slog.New(h))
+
+	echo := sh.Cmd("echo")
+	grep := sh.Cmd("grep")
+	sh.Pipe(echo("Hi"), grep("Bye")).RunContext(ctx, "")
+	// output:
+	// level=INFO msg=command cmd=echo args=[Hi] stage=1 exit_code=0 failed=false
+	// level=INFO msg=command cmd=grep args=[Bye] stage=2 exit_code=1 failed=true error="sh: \"grep Bye\" (stage 2) exited 1"
+}
+
+func TestWithLoggerOptions(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := sh.WithLogger(context.Background(), l, sh.LogLevel(slog.LevelDebug), sh.LogStderr())
+
+	// RunContext shares stderr with stdout, so tee it to keep it apart.
+	_, err := sh.Cmd("sh", "-c")("echo oops >&2; exit 3").AcceptExitCodes(3).TeeStderr(io.Discard).RunContext(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		Level    string
+		Msg      string
+		Cmd      string
+		ExitCode int `json:"exit_code"`
+		Failed   bool
+		Stderr   string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	if rec.Level != "DEBUG" || rec.Msg != "command" || rec.Cmd != "sh" || rec.ExitCode != 3 || rec.Failed || strings.TrimSpace(rec.Stderr) != "oops" {
+		t.Errorf("got record %+v", rec)
+	}
+}
+
+func TestDefaultLogger(t *testing.T) {
+	var buf bytes.Buffer
+	sh.DefaultLogger = slog.New(slog.NewTextHandler(&buf, nil))
+	defer func() { sh.DefaultLogger = nil }()
+
+	if _, err := sh.Cmd("true")().Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "cmd=true") {
+		t.Errorf("got log %q, want the command logged", buf.String())
+	}
+
+	buf.Reset()
+	ctx := sh.WithLogger(context.Background(), nil)
+	if _, err := sh.Cmd("true")().RunContext(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got log %q with logging turned off", buf.String())
+	}
+}
@@ -0,0 +1,81 @@
+package sh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Each runs the Executable that fn returns for each of inputs, running at most
+// concurrency of them at a time, and returns their outputs in the same order
+// as inputs.  Each output is what Run would have returned.  Every Executable
+// is run even if others fail; the error joins the errors of all that failed,
+// each prefixed by its input.  A concurrency of less than 1 is treated as 1.
+func Each(inputs []string, concurrency int, fn func(input string) Executable) ([]string, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	outs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, input string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			out, err := fn(input).Run()
+			outs[i] = out
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", input, err)
+			}
+		}(i, input)
+	}
+	wg.Wait()
+	return outs, errors.Join(errs...)
+}
+
+// Wait runs all of cmds at once and waits for them all to finish.  The error
+// joins the errors of all that failed, in the order of cmds.  Their stdout is
+// discarded, and so is their stderr, except as kept in an ExitError.
+func Wait(cmds ...Executable) error {
+	return WaitLimit(len(cmds), cmds...)
+}
+
+// WaitLimit works like Wait, but runs at most limit of cmds at a time.  A limit
+// of less than 1 is treated as 1.
+func WaitLimit(limit int, cmds ...Executable) error {
+	if limit < 1 {
+		limit = 1
+	}
+	errs := make([]error, len(cmds))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, c := range cmds {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, c Executable) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = c.runTo(context.Background(), nil, io.Discard, discardStderr{})
+		}(i, c)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// discardStderr is an io.Writer that discards stderr, as io.Discard does, but
+// is a different writer from it, so that commands still keep the end of their
+// stderr in their ExitErrors, which they don't for stderr shared with stdout.
+type discardStderr struct{}
+
+func (discardStderr) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,133 @@
+package sh_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleEach() {
+	echo := sh.Cmd("echo")
+
+	outs, err := sh.Each([]string{"one", "two", "three"}, 2, func(s string) sh.Executable {
+		return echo(s)
+	})
+	fmt.Print(strings.Join(outs, ""))
+	fmt.Print(err)
+	// output:
+	// one
+	// two
+	// three
+	// <nil>
+}
+
+func TestEachConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	track := sh.Func(func(r io.Reader, w io.Writer) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+
+	inputs := make([]string, 20)
+	if _, err := sh.Each(inputs, 3, func(string) sh.Executable { return track }); err != nil {
+		t.Fatal(err)
+	}
+	if most > 3 {
+		t.Errorf("expected at most 3 at a time, got %d", most)
+	}
+}
+
+func TestEachErrors(t *testing.T) {
+	test := sh.Cmd("test", "-d")
+
+	outs, err := sh.Each([]string{"/no/such/a", "/", "/no/such/b"}, 0, func(dir string) sh.Executable {
+		return test(dir)
+	})
+	if len(outs) != 3 {
+		t.Fatalf("expected 3 outputs, got %d", len(outs))
+	}
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, dir := range []string{"/no/such/a", "/no/such/b"} {
+		if !strings.Contains(err.Error(), dir) {
+			t.Errorf("expected error to mention %q, got %q", dir, err)
+		}
+	}
+	if strings.Contains(err.Error(), "/:") {
+		t.Errorf("did not expect an error for /, got %q", err)
+	}
+}
+
+func ExampleWait() {
+	sleep := sh.Cmd("sleep")
+	grep := sh.Cmd("grep")
+
+	start := time.Now()
+	err := sh.Wait(sleep("0.2"), sleep("0.2"), sh.PipeWith("hello\n", grep("goodbye")))
+	fmt.Println(time.Since(start) < 400*time.Millisecond)
+	fmt.Println(err)
+	// output:
+	// true
+	// sh: "grep goodbye" (stage 1) exited 1
+}
+
+func TestWaitPipeStderr(t *testing.T) {
+	// Both stages write stderr at once, so this is run with -race too.
+	noisy := sh.Shell("for i in 1 2 3 4 5; do echo noise >&2; done")
+	fail := sh.Shell("for i in 1 2 3 4 5; do echo noise >&2; done; echo oops >&2; exit 1")
+	err := sh.Wait(sh.Pipe(noisy, fail))
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || !strings.HasSuffix(exitErr.Stderr, "oops\n") {
+		t.Errorf("got %v, want an *sh.ExitError keeping the end of stderr", err)
+	}
+}
+
+func TestWaitLimit(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	track := sh.Func(func(io.Reader, io.Writer) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	cmds := make([]sh.Executable, 10)
+	for i := range cmds {
+		cmds[i] = track
+	}
+	if err := sh.WaitLimit(3, cmds...); err != nil {
+		t.Fatal(err)
+	}
+	if most != 3 {
+		t.Errorf("at most %d ran at once, want 3", most)
+	}
+
+	err := sh.WaitLimit(2, sh.Shell("exit 1"), track, sh.Shell("echo bad >&2; exit 2"))
+	if err == nil || !strings.Contains(err.Error(), "exited 1") || !strings.Contains(err.Error(), "exited 2: bad") {
+		t.Errorf("got error %v, want both failures", err)
+	}
+}
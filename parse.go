@@ -0,0 +1,167 @@
+package sh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Parse parses line as a shell pipeline and returns an Executable that runs
+// it, without involving a real shell:
+//
+//	sh.Parse(`echo "Hi there!" | grep -o Hi | wc -w`)
+//
+// is the same as
+//
+//	sh.Pipe(echo("Hi there!"), grep("-o", "Hi"), wc("-w"))
+//
+// Commands are split into words on spaces, tabs and newlines, and joined by |.
+// Single quotes, double quotes and backslashes work as they do in a POSIX
+// shell.  Anything else the shell would give a meaning to, such as
+// redirection, globbing, variables, command substitution, ;, && or &, is an
+// error rather than being passed along as a literal argument.
+func Parse(line string) (Executable, error) {
+	p := parser{line: line}
+	stages, err := p.parse()
+	if err != nil {
+		return Executable{}, err
+	}
+	cmds := make([]Executable, len(stages))
+	for i, words := range stages {
+		cmds[i] = Command(words[0], Args(words[1:]...))
+	}
+	if len(cmds) == 1 {
+		return cmds[0], nil
+	}
+	return Pipe(cmds...), nil
+}
+
+// parser holds the state of Parse as it works through a line.
+type parser struct {
+	line   string
+	stages [][]string
+	words  []string
+	word   strings.Builder
+	// inWord is true once the current word has begun, which may be before
+	// anything is written to it, as with an empty "".
+	inWord bool
+	// quoted is true if any part of the current word was quoted.
+	quoted bool
+}
+
+// assignment matches the start of a word that the shell would take as a
+// variable assignment.
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+func (p *parser) parse() ([][]string, error) {
+	line := p.line
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if err := p.endWord(i); err != nil {
+				return nil, err
+			}
+		case c == '|':
+			if err := p.endStage(i); err != nil {
+				return nil, err
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, p.errorf(i, "unterminated single quote")
+			}
+			p.word.WriteString(line[i+1 : i+1+end])
+			p.inWord, p.quoted = true, true
+			i += end + 1
+		case c == '"':
+			p.inWord, p.quoted = true, true
+			start := i
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil, p.errorf(start, "unterminated double quote")
+				}
+				c := line[i]
+				if c == '"' {
+					break
+				}
+				switch {
+				case c == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`\n", line[i+1]) >= 0:
+					i++
+					if line[i] != '\n' {
+						p.word.WriteByte(line[i])
+					}
+				case c == '$' || c == '`':
+					return nil, p.errorf(i, "substitution is not supported")
+				default:
+					p.word.WriteByte(c)
+				}
+			}
+		case c == '\\':
+			if i+1 >= len(line) {
+				return nil, p.errorf(i, "trailing backslash")
+			}
+			i++
+			if line[i] != '\n' {
+				p.word.WriteByte(line[i])
+				p.inWord, p.quoted = true, true
+			}
+		case c == '<' || c == '>':
+			return nil, p.errorf(i, "redirection is not supported")
+		case c == '*' || c == '?' || c == '[':
+			return nil, p.errorf(i, "globbing is not supported")
+		case c == '$' || c == '`':
+			return nil, p.errorf(i, "substitution is not supported")
+		case c == ';' || c == '&':
+			return nil, p.errorf(i, "%q is not supported", c)
+		case c == '(' || c == ')':
+			return nil, p.errorf(i, "subshells are not supported")
+		case (c == '#' || c == '~') && !p.inWord:
+			if c == '#' {
+				return nil, p.errorf(i, "comments are not supported")
+			}
+			return nil, p.errorf(i, "tilde expansion is not supported")
+		default:
+			p.word.WriteByte(c)
+			p.inWord = true
+		}
+	}
+	if err := p.endStage(len(line)); err != nil {
+		return nil, err
+	}
+	return p.stages, nil
+}
+
+// endWord ends the current word, if there is one.  i is the offset in the
+// line where it ended.
+func (p *parser) endWord(i int) error {
+	if !p.inWord {
+		return nil
+	}
+	w := p.word.String()
+	if len(p.words) == 0 && !p.quoted && assignment.MatchString(w) {
+		return p.errorf(i-len(w), "variable assignment is not supported")
+	}
+	p.words = append(p.words, w)
+	p.word.Reset()
+	p.inWord, p.quoted = false, false
+	return nil
+}
+
+// endStage ends the current command of the pipeline.  i is the offset in the
+// line where it ended.
+func (p *parser) endStage(i int) error {
+	if err := p.endWord(i); err != nil {
+		return err
+	}
+	if len(p.words) == 0 {
+		return p.errorf(i, "missing command")
+	}
+	p.stages = append(p.stages, p.words)
+	p.words = nil
+	return nil
+}
+
+func (p *parser) errorf(i int, format string, args ...any) error {
+	return fmt.Errorf("parsing %q: %s at offset %d", p.line, fmt.Sprintf(format, args...), i)
+}
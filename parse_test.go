@@ -0,0 +1,90 @@
+package sh_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleParse() {
+	cmd, err := sh.Parse(`echo "Hi there!" | grep -o Hi | tr '[:lower:]' '[:upper:]'`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(cmd)
+	// output:
+	// HI
+}
+
+func TestParseWords(t *testing.T) {
+	// each line is run with printf, which shows how it was split into words
+	tests := map[string]string{
+		`a`:                  `<a>`,
+		`  a   b  `:          `<a><b>`,
+		`'b c' "d e"`:        `<b c><d e>`,
+		`b\ c`:               `<b c>`,
+		`"" ''`:              `<><>`,
+		`"say \"hi\" \$x"`:   `<say "hi" $x>`,
+		`'it'\''s'`:          `<it's>`,
+		`"\n" '\n'`:          `<\n><\n>`,
+		`x=1 b#c d~`:         `<x=1><b#c><d~>`,
+		"a \\\nb":            `<a><b>`,
+		`'x|y' "*" \<`:       `<x|y><*><<>`,
+		`'$(b)' "a;b&c(d)"`:  `<$(b)><a;b&c(d)>`,
+		`ünï"cö"'dé'`:        `<ünïcödé>`,
+		"tab\there\nnewline": `<tab><here><newline>`,
+	}
+	for args, expected := range tests {
+		line := `printf '<%s>' ` + args
+		cmd, err := sh.Parse(line)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", line, err)
+			continue
+		}
+		out, err := cmd.Run()
+		if err != nil || out != expected {
+			t.Errorf("Parse(%q): expected %s, got %q, %v", line, expected, out, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		``:              "missing command",
+		`a |`:           "missing command",
+		`| a`:           "missing command",
+		`a || b`:        "missing command",
+		`a > b`:         "redirection",
+		`a <b`:          "redirection",
+		`a 2>&1`:        "redirection",
+		`a *.go`:        "globbing",
+		`a file?`:       "globbing",
+		`a [ab]`:        "globbing",
+		`a $HOME`:       "substitution",
+		"a `b`":         "substitution",
+		`a "$(b)"`:      "substitution",
+		`a; b`:          `';'`,
+		`a && b`:        `'&'`,
+		`a &`:           `'&'`,
+		`(a)`:           "subshells",
+		`a # comment`:   "comments",
+		`a ~/x`:         "tilde",
+		`X=1 a`:         "variable assignment",
+		`a 'b`:          "unterminated single quote",
+		`a "b`:          "unterminated double quote",
+		`a \`:           "trailing backslash",
+		`a | X=1 b | c`: "variable assignment",
+	}
+	for line, expected := range tests {
+		_, err := sh.Parse(line)
+		if err == nil {
+			t.Errorf("Parse(%q): expected an error", line)
+			continue
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Parse(%q): expected error about %s, got %v", line, expected, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sh
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"labix.org/v2/pipe"
+)
+
+// Start starts the Executable with the given string as standard input, and
+// returns a Process for it without waiting for it to finish.
+func (c Executable) Start(stdin string) (*Process, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Process{cancel: cancel, started: make(chan struct{}), done: make(chan struct{})}
+	p.s = pipe.NewState(&p.out, &p.out)
+	p.s.Stdin = strings.NewReader(stdin)
+	if DryRun {
+		out, err := c.dryRun()
+		p.out.Write([]byte(out))
+		p.err = err
+		p.setPid(0)
+		close(p.done)
+		return p, nil
+	}
+	hook := func(cmd *exec.Cmd, _ redactor) func() {
+		return func() { p.setPid(cmd.Process.Pid) }
+	}
+	if err := withHooks(c.Pipe, hook)(p.s); err != nil {
+		cancel()
+		return nil, err
+	}
+	// Tasks are killed through ctx, so that a killed Executable fails with
+	// the one error of runTasks, rather than one from each of its commands.
+	go func() {
+		p.err = runTasks(ctx, p.s)
+		p.setPid(0)
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Process is an Executable that has been started with Start.
+type Process struct {
+	s      *pipe.State
+	out    buffer
+	err    error
+	cancel context.CancelFunc
+
+	once    sync.Once
+	pid     int
+	started chan struct{}
+	done    chan struct{}
+}
+
+// Pid returns the process ID of the command, waiting for it to start if it
+// hasn't yet.  If the Executable runs more than one command, as a Pipe does,
+// it is the ID of the first one to start.  If no command ever starts, Pid
+// returns 0.
+func (p *Process) Pid() int {
+	<-p.started
+	return p.pid
+}
+
+// setPid records pid as the result of Pid, if no pid has been recorded yet.
+func (p *Process) setPid(pid int) {
+	p.once.Do(func() {
+		p.pid = pid
+		close(p.started)
+	})
+}
+
+// Wait waits for the Executable to finish, and returns the combined stdout
+// and stderr and the error if any, as Run does.  Every call to Wait returns
+// the same result, so it is safe to call more than once.
+func (p *Process) Wait() (string, error) {
+	<-p.done
+	return p.out.String(), p.err
+}
+
+// Kill kills the Executable if it is still running.  It does not wait for it
+// to exit; call Wait for that, which then returns context.Canceled, as
+// RunContext does when its context is cancelled.  Calling Kill more than once,
+// or after the Executable has finished, does nothing.
+func (p *Process) Kill() {
+	p.cancel()
+}
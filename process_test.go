@@ -0,0 +1,85 @@
+package sh_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleExecutable_Start() {
+	sleep := sh.Cmd("sleep")
+	echo := sh.Cmd("echo")
+
+	// start both, then wait for both
+	p1, err := sh.And(sleep("0.1"), echo("first")).Start("")
+	if err != nil {
+		panic(err)
+	}
+	p2, err := echo("second").Start("")
+	if err != nil {
+		panic(err)
+	}
+	out1, err1 := p1.Wait()
+	out2, err2 := p2.Wait()
+	fmt.Print(out1, out2)
+	fmt.Println(err1, err2)
+	// output:
+	// first
+	// second
+	// <nil> <nil>
+}
+
+func TestProcessKill(t *testing.T) {
+	sleep := sh.Cmd("sleep")
+
+	p, err := sleep("10").Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Pid() <= 0 {
+		t.Errorf("expected a pid, got %d", p.Pid())
+	}
+	start := time.Now()
+	p.Kill()
+	p.Kill()
+	if _, err := p.Wait(); err == nil {
+		t.Error("expected an error from a killed process")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("process was not killed, ran for %v", elapsed)
+	}
+	p.Kill()
+	if _, err2 := p.Wait(); err2 == nil {
+		t.Error("expected Wait to return the same error again")
+	}
+}
+
+func TestProcessKillPipe(t *testing.T) {
+	sleep := sh.Cmd("sleep")
+	p, err := sh.Pipe(sleep("10"), sleep("10")).Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Pid()
+	p.Kill()
+	if _, err := p.Wait(); err != context.Canceled {
+		t.Errorf("got %q, want just %q", err, context.Canceled)
+	}
+}
+
+func TestProcessPidNoCommand(t *testing.T) {
+	p, err := sh.Grep("x").Start("x\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := p.Pid(); pid != 0 {
+		t.Errorf("expected pid 0 with no command, got %d", pid)
+	}
+	out, err := p.Wait()
+	if out != "x\n" || err != nil {
+		t.Errorf("expected %q, <nil>, got %q, %v", "x\n", out, err)
+	}
+}
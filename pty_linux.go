@@ -0,0 +1,137 @@
+//go:build linux
+
+package sh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// terminal is a pseudo-terminal that a command runs in.  What is written to
+// master is read by the command from tty, and what the command writes to tty
+// is read from master.
+type terminal struct {
+	master, tty *os.File
+	stdin       io.Reader
+	copied      chan struct{}
+}
+
+// openTerminal opens a pseudo-terminal and sets up cmd to run in it, with the
+// terminal as its controlling terminal and as its stdin, stdout and stderr.
+// The stdin cmd had is copied to the terminal once started is called, and the
+// output of the terminal is copied to the stdout cmd had.
+func openTerminal(cmd *exec.Cmd) (*terminal, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("sh: opening pty: %w", err)
+	}
+	tty, err := openTTY(master)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("sh: opening pty: %w", err)
+	}
+	t := &terminal{master: master, tty: tty, stdin: cmd.Stdin, copied: make(chan struct{})}
+	stdout := cmd.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	go func() {
+		defer close(t.copied)
+		// Once every copy of tty is closed, reading master fails with EIO,
+		// which is how the end of the output is seen.
+		io.Copy(stdout, master)
+	}()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// A new session is a new process group too, and setting the group as
+	// well would fail.
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	return t, nil
+}
+
+// openTTY unlocks and opens the terminal end of the pseudo-terminal whose
+// master end is master, sized to 80 columns by 24 rows.
+func openTTY(master *os.File) (*os.File, error) {
+	conn, err := master.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var n uint32
+	var unlock int32
+	size := struct{ rows, cols, x, y uint16 }{rows: 24, cols: 80}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		for _, op := range []struct {
+			req uintptr
+			arg unsafe.Pointer
+		}{
+			{syscall.TIOCGPTN, unsafe.Pointer(&n)},
+			{syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)},
+			{syscall.TIOCSWINSZ, unsafe.Pointer(&size)},
+		} {
+			if _, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, op.req, uintptr(op.arg)); errno != 0 {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errno != 0 {
+		return nil, errno
+	}
+	return os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(n), 10), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+}
+
+// started closes the terminal end of t, which the command now has open, and
+// starts copying stdin to the terminal.  Once stdin is used up, the end of the
+// input is typed, as Ctrl-D does.
+func (t *terminal) started() {
+	t.tty.Close()
+	go func() {
+		w := &lastByteWriter{w: t.master}
+		if t.stdin != nil {
+			io.Copy(w, t.stdin)
+		}
+		// Ctrl-D only ends the input at the start of a line; elsewhere, it
+		// ends the line, and a second one is needed.
+		eof := "\x04"
+		if w.n > 0 && w.last != '\n' {
+			eof += "\x04"
+		}
+		io.WriteString(t.master, eof)
+	}()
+}
+
+// close waits for the output of the terminal to be copied, and closes it.
+func (t *terminal) close() {
+	t.tty.Close()
+	<-t.copied
+	t.master.Close()
+}
+
+// lastByteWriter is an io.Writer that remembers the last byte written to w.
+type lastByteWriter struct {
+	w    io.Writer
+	n    int64
+	last byte
+}
+
+func (lw *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := lw.w.Write(p)
+	if n > 0 {
+		lw.n += int64(n)
+		lw.last = p[n-1]
+	}
+	return n, err
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package sh_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleExecutable_WithPTY() {
+	out, err := sh.Shell("test -t 1 && echo terminal || echo pipe").WithPTY().Run()
+	fmt.Printf("%q %v\n", out, err)
+	// output:
+	// "terminal\r\n" <nil>
+}
+
+func TestWithPTYInput(t *testing.T) {
+	// Without a newline at the end, it takes a second Ctrl-D to end the
+	// input, or the second read would wait forever.
+	for _, stdin := range []string{"one\ntwo\n", "one\ntwo"} {
+		out, err := sh.Shell(`read a; read b; echo "got $a $b"`).WithPTY().RunWith(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The terminal echoes the input as well.
+		if !strings.Contains(out, "got one two\r\n") {
+			t.Errorf("with stdin %q got %q, want %q in it", stdin, out, "got one two\r\n")
+		}
+	}
+}
+
+func TestWithPTYExit(t *testing.T) {
+	_, err := sh.Shell("exit 3").WithPTY().Run()
+	if err == nil || !strings.Contains(err.Error(), "exited 3") {
+		t.Errorf("got error %v, want exit 3", err)
+	}
+}
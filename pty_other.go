@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sh
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// terminal is a pseudo-terminal that a command runs in.  They are only
+// supported on Linux.
+type terminal struct{}
+
+// openTerminal fails, since pseudo-terminals are not supported.
+func openTerminal(cmd *exec.Cmd) (*terminal, error) {
+	return nil, errors.New("sh: WithPTY is not supported on " + runtime.GOOS)
+}
+
+func (t *terminal) started() {}
+
+func (t *terminal) close() {}
@@ -0,0 +1,93 @@
+package sh
+
+import "strings"
+
+// Quote returns arg quoted so that a POSIX shell reads it back as a single
+// word with exactly the same contents, for building scripts for Shell.  An
+// arg made only of letters, digits and the characters @%+=:,./_- is returned
+// as is.  Anything else is put in single quotes, inside which the shell treats
+// every character literally; each single quote in arg ends the quoting, is
+// escaped with a backslash, and starts it again.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if strings.IndexFunc(arg, needsQuote) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// QuoteAll quotes each of args with Quote, and joins them with spaces.
+func QuoteAll(args ...string) string {
+	return quoteAll(Quote, args)
+}
+
+// needsQuote reports whether r would need quoting in a POSIX shell word.
+func needsQuote(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("@%+=:,./_-", r)
+}
+
+// QuoteCmd returns arg quoted for a script run by cmd.exe, which is what Shell
+// uses on Windows.  The argument is first quoted the way Windows programs
+// split their command lines, and then every character cmd.exe treats
+// specially is escaped with ^.  cmd.exe still expands %VAR% references,
+// which cannot be escaped on its command line.
+func QuoteCmd(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\v\"") {
+		return escapeCmd(arg)
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for _, r := range arg {
+		switch r {
+		case '\\':
+			slashes++
+		case '"':
+			// backslashes before a quote must be doubled, and the quote
+			// itself escaped
+			b.WriteString(strings.Repeat(`\`, 2*slashes+1))
+			slashes = 0
+		default:
+			b.WriteString(strings.Repeat(`\`, slashes))
+			slashes = 0
+		}
+		if r != '\\' {
+			b.WriteRune(r)
+		}
+	}
+	// backslashes before the closing quote must be doubled too
+	b.WriteString(strings.Repeat(`\`, 2*slashes))
+	b.WriteByte('"')
+	return escapeCmd(b.String())
+}
+
+// QuoteCmdAll quotes each of args with QuoteCmd, and joins them with spaces.
+func QuoteCmdAll(args ...string) string {
+	return quoteAll(QuoteCmd, args)
+}
+
+// escapeCmd escapes the characters of s that cmd.exe treats specially.
+func escapeCmd(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`()!^"<>&|`, r) {
+			b.WriteByte('^')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func quoteAll(quote func(string) string, args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,64 @@
+package sh_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleQuote() {
+	echo := "echo " + sh.QuoteAll("Hi there!", "it's", "$HOME")
+
+	fmt.Println(echo)
+	fmt.Print(sh.Shell(echo))
+	// output:
+	// echo 'Hi there!' 'it'\''s' '$HOME'
+	// Hi there! it's $HOME
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"":              "''",
+		"plain":         "plain",
+		"/usr/bin/a-b":  "/usr/bin/a-b",
+		"two words":     "'two words'",
+		"it's":          `'it'\''s'`,
+		"$(rm -rf /)":   "'$(rm -rf /)'",
+		"a;b|c&d":       "'a;b|c&d'",
+		"*.go":          "'*.go'",
+		"line\nbreak":   "'line\nbreak'",
+		`back\slash`:    `'back\slash'`,
+		"ünïcödé":       "'ünïcödé'",
+		"key=value,x:y": "key=value,x:y",
+	}
+	shell := sh.Cmd("sh", "-c")
+	for in, expected := range tests {
+		if got := sh.Quote(in); got != expected {
+			t.Errorf("Quote(%q): expected %s, got %s", in, expected, got)
+		}
+		// the shell must read the quoted word back unchanged
+		out, err := shell(`printf %s ` + sh.Quote(in)).Run()
+		if err != nil || out != in {
+			t.Errorf("shell read %s back as %q, %v", sh.Quote(in), out, err)
+		}
+	}
+}
+
+func TestQuoteCmd(t *testing.T) {
+	tests := map[string]string{
+		"":                `^"^"`,
+		"plain":           "plain",
+		"two words":       `^"two words^"`,
+		`say "hi"`:        `^"say \^"hi\^"^"`,
+		`C:\dir\`:         `C:\dir\`,
+		`C:\my dir\`:      `^"C:\my dir\\^"`,
+		"a&b|c":           "a^&b^|c",
+		"(x) <y> ^z! & w": `^"^(x^) ^<y^> ^^z^! ^& w^"`,
+	}
+	for in, expected := range tests {
+		if got := sh.QuoteCmd(in); got != expected {
+			t.Errorf("QuoteCmd(%q): expected %s, got %s", in, expected, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package sh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"labix.org/v2/pipe"
+)
+
+// Retry returns an Executable that runs cmd, running it again if it fails, up
+// to attempts times in all.  It waits backoff after the first failure, and
+// twice as long after each failure after that.  See RetryBackoff to choose a
+// different schedule.
+func Retry(attempts int, backoff time.Duration, cmd Executable) Executable {
+	return RetryBackoff(attempts, ExponentialBackoff(backoff), cmd)
+}
+
+// RetryBackoff works like Retry, but waits as long as b says between attempts.
+//
+// Since every attempt needs the same input, stdin is read into memory before
+// the first attempt.  Output is held back until an attempt succeeds, so that a
+// failed attempt never sends partial output down a pipe.  If every attempt
+// fails, the stderr and error of the last one are returned.  If the pipe is
+// killed, for instance by WithTimeout or a context passed to RunContext, no
+// further attempts are made.
+func RetryBackoff(attempts int, b Backoff, cmd Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, []Executable{cmd}, func(ctx context.Context, s *pipe.State) error {
+			in, err := io.ReadAll(stdin(s))
+			if err != nil {
+				return err
+			}
+			for attempt := 1; ; attempt++ {
+				var stdout, stderr bytes.Buffer
+				err = cmd.runInWith(ctx, s, bytes.NewReader(in), &stdout, &stderr)
+				if err == nil || attempt >= attempts || ctx.Err() != nil {
+					if _, werr := stdout.WriteTo(s.Stdout); err == nil {
+						err = werr
+					}
+					stderr.WriteTo(s.Stderr)
+					return err
+				}
+				select {
+				case <-time.After(b.Delay(attempt)):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}}
+}
+
+// Backoff decides how long RetryBackoff waits between attempts.
+type Backoff interface {
+	// Delay returns how long to wait after the given failed attempt, counting
+	// from 1, before trying again.
+	Delay(attempt int) time.Duration
+}
+
+// BackoffFunc adapts an ordinary function to a Backoff.
+type BackoffFunc func(attempt int) time.Duration
+
+// Delay returns f(attempt).
+func (f BackoffFunc) Delay(attempt int) time.Duration {
+	return f(attempt)
+}
+
+// ExponentialBackoff is a Backoff that waits its own duration after the first
+// failed attempt, and doubles the wait after each failure after that.
+type ExponentialBackoff time.Duration
+
+// Delay returns b * 2^(attempt-1).
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	return time.Duration(b) << uint(attempt-1)
+}
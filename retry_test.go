@@ -0,0 +1,77 @@
+package sh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleRetry() {
+	shell := sh.Cmd("sh", "-c")
+
+	name := "ExampleRetryTest"
+	defer os.Remove(name)
+
+	// fails until it has been run three times
+	flaky := shell(`echo try >> ` + name + `; test $(wc -l < ` + name + `) -ge 3 && echo ok`)
+
+	fmt.Print(sh.Retry(5, time.Millisecond, flaky))
+	// output:
+	// ok
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	shell := sh.Cmd("sh", "-c")
+	runs := 0
+	counter := sh.Func(func(r io.Reader, w io.Writer) error {
+		runs++
+		_, err := io.Copy(w, r)
+		return err
+	})
+	fail := sh.Pipe(counter, shell("cat >/dev/null; echo partial; echo oops >&2; exit 3"))
+
+	out, err := sh.Retry(3, time.Millisecond, fail).RunWith("input")
+	if runs != 3 {
+		t.Errorf("expected 3 attempts, got %d", runs)
+	}
+	if out != "partial\noops\n" {
+		t.Errorf("expected last attempt's output, got %q", out)
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	fail := sh.Cmd("false")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sh.Retry(10, time.Second, fail()).RunContext(ctx, "")
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retries ran past the deadline, for %v", elapsed)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := sh.ExponentialBackoff(time.Second)
+	for attempt, expected := range []time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		if attempt == 0 {
+			continue
+		}
+		if d := b.Delay(attempt); d != expected {
+			t.Errorf("attempt %d: expected %v, got %v", attempt, expected, d)
+		}
+	}
+}
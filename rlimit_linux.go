@@ -0,0 +1,113 @@
+//go:build linux
+
+package sh
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// startLimited starts cmd with its resource limits set to rlimits.  The
+// limits are set by prlimit, which cmd is run by, so that they are in place
+// before the command itself runs.
+func startLimited(cmd *exec.Cmd, rlimits []rlimit) error {
+	if len(rlimits) == 0 || cmd.Err != nil {
+		return cmd.Start()
+	}
+	prlimit, err := exec.LookPath("prlimit")
+	if err != nil {
+		return fmt.Errorf("sh: WithRlimit needs prlimit, from util-linux: %w", err)
+	}
+	args := []string{"prlimit"}
+	// A limit set again replaces the one set before.
+	set := map[int]int{}
+	for _, l := range rlimits {
+		opt, ok := prlimitOptions[l.resource]
+		if !ok {
+			return fmt.Errorf("sh: WithRlimit: unknown resource %d", l.resource)
+		}
+		arg := fmt.Sprintf("--%s=%s:%s", opt, rlimitValue(l.soft), rlimitValue(l.hard))
+		if i, ok := set[l.resource]; ok {
+			args[i] = arg
+			continue
+		}
+		set[l.resource] = len(args)
+		args = append(args, arg)
+	}
+	path, cmdArgs := cmd.Path, cmd.Args
+	cmd.Path = prlimit
+	cmd.Args = append(append(args, "--", path), cmdArgs[1:]...)
+	err = cmd.Start()
+	cmd.Path, cmd.Args = path, cmdArgs
+	return err
+}
+
+// prlimitOptions are the options of prlimit that set each resource limit, for
+// the resources package syscall names on every architecture.
+var prlimitOptions = map[int]string{
+	syscall.RLIMIT_AS:     "as",
+	syscall.RLIMIT_CORE:   "core",
+	syscall.RLIMIT_CPU:    "cpu",
+	syscall.RLIMIT_DATA:   "data",
+	syscall.RLIMIT_FSIZE:  "fsize",
+	syscall.RLIMIT_NOFILE: "nofile",
+	syscall.RLIMIT_STACK:  "stack",
+}
+
+// rlimitValue returns v as prlimit takes it.
+func rlimitValue(v uint64) string {
+	if v == math.MaxUint64 {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// rlimitHit returns the limit of rlimits that the command of err was killed
+// for going past, if it was.  Only going past the CPU time and file size
+// limits sends a signal, SIGXCPU or SIGXFSZ, that says so.
+func rlimitHit(err *ExitError, rlimits []rlimit) (rlimit, bool) {
+	ws, ok := err.Err.Sys().(syscall.WaitStatus)
+	if len(rlimits) == 0 || !ok || !ws.Signaled() {
+		return rlimit{}, false
+	}
+	var resource int
+	switch ws.Signal() {
+	case syscall.SIGXCPU:
+		resource = syscall.RLIMIT_CPU
+	case syscall.SIGXFSZ:
+		resource = syscall.RLIMIT_FSIZE
+	default:
+		return rlimit{}, false
+	}
+	// The limit set last is the one the command had.
+	for i := len(rlimits) - 1; i >= 0; i-- {
+		if rlimits[i].resource == resource {
+			return rlimits[i], true
+		}
+	}
+	return rlimit{}, false
+}
+
+// rlimitName returns what resource limits, for messages.
+func rlimitName(resource int) string {
+	switch resource {
+	case syscall.RLIMIT_AS:
+		return "memory"
+	case syscall.RLIMIT_CORE:
+		return "core file size"
+	case syscall.RLIMIT_CPU:
+		return "CPU time"
+	case syscall.RLIMIT_DATA:
+		return "data size"
+	case syscall.RLIMIT_FSIZE:
+		return "file size"
+	case syscall.RLIMIT_NOFILE:
+		return "open file"
+	case syscall.RLIMIT_STACK:
+		return "stack size"
+	}
+	return fmt.Sprintf("resource %d", resource)
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package sh_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/natefinch/sh"
+	"github.com/natefinch/sh/shtest"
+)
+
+func TestWithRlimit(t *testing.T) {
+	needPrlimit(t)
+	out, err := sh.Shell("ulimit -S -n; ulimit -H -n").WithRlimit(syscall.RLIMIT_NOFILE, 50, 60).Output()
+	if err != nil || out != "50\n60\n" {
+		t.Errorf("got %q, %v, want %q", out, err, "50\n60\n")
+	}
+	// A process started at once is held to the limits too.
+	out, err = sh.Shell("sh -c 'ulimit -n'").WithRlimit(syscall.RLIMIT_NOFILE, 40, 60).Output()
+	if err != nil || out != "40\n" {
+		t.Errorf("child process: got %q, %v, want %q", out, err, "40\n")
+	}
+
+	file := filepath.Join(t.TempDir(), "big")
+	big := sh.Shell("exec head -c 100000 /dev/zero > " + sh.Quote(file))
+	_, err = big.WithRlimit(syscall.RLIMIT_FSIZE, 1000, math.MaxUint64).Run()
+	var limitErr *sh.RlimitError
+	if !errors.As(err, &limitErr) || limitErr.Resource != syscall.RLIMIT_FSIZE || limitErr.Limit != 1000 {
+		t.Fatalf("got %v, want an *sh.RlimitError for the file size", err)
+	}
+	if msg := err.Error(); !strings.HasSuffix(msg, "(over its file size limit of 1000)") {
+		t.Errorf("got message %q, want it to name the limit", msg)
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("got %v, want an *sh.ExitError too", err)
+	}
+	if _, err := big.WithRlimit(syscall.RLIMIT_FSIZE, 1000000, 1000000).Run(); err != nil {
+		t.Errorf("within the limit: got %v", err)
+	}
+}
+
+func TestWithRlimitCPU(t *testing.T) {
+	needPrlimit(t)
+	if testing.Short() {
+		t.Skip("uses a second of CPU time")
+	}
+	spin := sh.Shell("while :; do :; done")
+	_, err := spin.WithRlimit(syscall.RLIMIT_CPU, 1, 2).Run()
+	var limitErr *sh.RlimitError
+	if !errors.As(err, &limitErr) || limitErr.Resource != syscall.RLIMIT_CPU {
+		t.Errorf("got %v, want an *sh.RlimitError for the CPU time", err)
+	}
+}
+
+func TestWithRlimitRunner(t *testing.T) {
+	needPrlimit(t)
+	limited := sh.Shell("ulimit -n").WithRlimit(syscall.RLIMIT_NOFILE, 50, 60)
+	ctx := sh.WithRunner(context.Background(), &sh.ExecRunner{})
+	out, err := limited.RunContext(ctx, "")
+	if err != nil || out != "50\n" {
+		t.Errorf("with &sh.ExecRunner{}: got %q, %v, want %q", out, err, "50\n")
+	}
+	// Another runner couldn't be made to set the limits, so none is asked
+	// to run the command.
+	fake := &shtest.Fake{}
+	if _, err := limited.RunContext(sh.WithRunner(context.Background(), fake), ""); err == nil {
+		t.Error("with another runner: got no error")
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("with another runner: ran %v", calls)
+	}
+}
+
+func TestWithRlimitUnsettable(t *testing.T) {
+	if syscall.Geteuid() == 0 {
+		t.Skip("root may raise its hard limits")
+	}
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatal(err)
+	}
+	if lim.Max == math.MaxUint64 {
+		t.Skip("no hard limit to go past")
+	}
+	_, err := sh.Cmd("true")().WithRlimit(syscall.RLIMIT_NOFILE, lim.Max+1, lim.Max+1).Run()
+	if err == nil {
+		t.Error("raising the hard limit without privileges succeeded")
+	}
+}
+
+// needPrlimit skips the test if prlimit, which WithRlimit runs commands with,
+// is not installed.
+func needPrlimit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("prlimit"); err != nil {
+		t.Skip("prlimit is not installed")
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package sh
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// startLimited starts cmd, or fails if it has resource limits, since they are
+// not supported.
+func startLimited(cmd *exec.Cmd, rlimits []rlimit) error {
+	if len(rlimits) > 0 {
+		return errors.New("sh: WithRlimit is not supported on " + runtime.GOOS)
+	}
+	return cmd.Start()
+}
+
+// rlimitHit reports that err is not for going past a limit, since none are
+// set.
+func rlimitHit(err *ExitError, rlimits []rlimit) (rlimit, bool) {
+	return rlimit{}, false
+}
+
+// rlimitName returns what resource limits, for messages.
+func rlimitName(resource int) string {
+	return fmt.Sprintf("resource %d", resource)
+}
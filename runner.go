@@ -0,0 +1,62 @@
+package sh
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CommandRunner runs the commands of Executables.  Stages written in Go, such
+// as Func and Grep, are not run by it.  Replacing the CommandRunner makes it
+// possible to test code that uses this package without starting any
+// processes; package shtest has one that records the commands it is asked to
+// run and replies with canned output.
+type CommandRunner interface {
+	// Run runs cmd and waits for it to finish.  cmd is ready to start, with
+	// its Dir, Env, Stdin, Stdout and Stderr set, and ctx is done if the
+	// command should be killed early.  A non-nil error fails the stage.
+	Run(ctx context.Context, cmd *exec.Cmd) error
+}
+
+// ExecRunner is the CommandRunner that really runs commands, with os/exec.
+type ExecRunner struct{}
+
+// Run starts cmd and waits for it to exit.
+func (ExecRunner) Run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Wait()
+}
+
+// DefaultRunner runs every command that is not run with a context from
+// WithRunner.  It should only be changed while no Executables are running,
+// such as at the start of a test.
+var DefaultRunner CommandRunner = ExecRunner{}
+
+// WithRunner returns a copy of ctx that makes RunContext run commands with r
+// in place of DefaultRunner.
+func WithRunner(ctx context.Context, r CommandRunner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, r)
+}
+
+// runnerKey is the context key for the CommandRunner set with WithRunner.
+type runnerKey struct{}
+
+// runnerOf returns the CommandRunner to run commands set up with st.
+func runnerOf(st settings) CommandRunner {
+	if st.runner != nil {
+		return st.runner
+	}
+	return DefaultRunner
+}
+
+// isExecRunner reports whether r is an ExecRunner, which the commands of
+// Executables are then run by directly, so that they can be started in ways
+// Run can't ask for.
+func isExecRunner(r CommandRunner) bool {
+	switch r.(type) {
+	case ExecRunner, *ExecRunner:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package sh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/natefinch/sh"
+	"github.com/natefinch/sh/shtest"
+)
+
+func TestWithRunner(t *testing.T) {
+	fake := &shtest.Fake{Default: shtest.Reply{Stdout: "faked\n"}}
+	ctx := sh.WithRunner(context.Background(), fake)
+
+	script := sh.And(sh.Cmd("deploy")("--prod"), sh.Cmd("echo")("done"))
+	out, err := script.RunContext(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "faked\nfaked\n" {
+		t.Errorf("got %q, want %q", out, "faked\nfaked\n")
+	}
+	if n := len(fake.Calls()); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+
+	out, err = sh.Cmd("echo")("real").Run()
+	if err != nil || out != "real\n" {
+		t.Errorf("without WithRunner got %q, %v, want %q, nil", out, err, "real\n")
+	}
+}
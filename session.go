@@ -0,0 +1,79 @@
+package sh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Session runs Executables the way an interactive shell runs commands, with a
+// working directory and environment variables that carry over from one
+// command to the next, as cd and export do.  The zero value runs commands in
+// the current directory with the environment of the current process.
+//
+// A Session may be copied, to branch off a base configuration; changes made
+// to the copy do not affect the original.  A Session may be used to run
+// Executables from several goroutines at once, but must not be changed with
+// Cd or Setenv while it is in use.
+type Session struct {
+	dir string
+	env []string
+}
+
+// Cd changes the directory the session runs commands in, like cd.  A relative
+// dir is relative to the session's current directory.  Cd fails, leaving the
+// directory as it was, if dir is not a directory.
+func (s *Session) Cd(dir string) error {
+	if !filepath.IsAbs(dir) && s.dir != "" {
+		dir = filepath.Join(s.dir, dir)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("cd %s: not a directory", dir)
+	}
+	s.dir = dir
+	return nil
+}
+
+// Dir returns the directory the session runs commands in, or "" for the
+// current directory.
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Setenv sets the environment variable key to value for the commands the
+// session runs from now on, like export in the shell.
+func (s *Session) Setenv(key, value string) {
+	// Always copy, so that sessions copied from s don't share the change.
+	env := make([]string, 0, len(s.env)+1)
+	prefix := key + "="
+	for _, kv := range s.env {
+		if !strings.HasPrefix(kv, prefix) {
+			env = append(env, kv)
+		}
+	}
+	s.env = append(env, prefix+value)
+}
+
+// Apply returns an Executable that runs c in the session's directory and with
+// its environment variables added to c's environment.  The Executable keeps
+// the settings the session had when Apply was called.
+func (s *Session) Apply(c Executable) Executable {
+	if len(s.env) > 0 {
+		c = c.WithExtraEnv(s.env...)
+	}
+	if s.dir != "" {
+		c = c.WithDir(s.dir)
+	}
+	return c
+}
+
+// Run runs c in the session, as Apply does, and returns its combined stdout
+// and stderr, and the error if any, as Executable.Run does.
+func (s *Session) Run(c Executable) (string, error) {
+	return s.Apply(c).Run()
+}
@@ -0,0 +1,62 @@
+package sh_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleSession() {
+	var s sh.Session
+	s.Cd("/")
+	s.Setenv("GREETING", "hello")
+
+	out, err := s.Run(sh.Shell(`echo "$GREETING from $(pwd)"`))
+	fmt.Print(out)
+	fmt.Println(err)
+	// output:
+	// hello from /
+	// <nil>
+}
+
+func TestSessionCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer writeTempFile(filepath.Join(dir, "file"), "")()
+
+	var base sh.Session
+	if err := base.Cd(dir); err != nil {
+		t.Fatal(err)
+	}
+	base.Setenv("A", "base")
+
+	branch := base
+	if err := branch.Cd("sub"); err != nil {
+		t.Fatal(err)
+	}
+	branch.Setenv("A", "branch")
+	if err := branch.Cd("missing"); err == nil {
+		t.Error("no error changing to a missing directory")
+	}
+	if err := branch.Cd(filepath.Join("..", "file")); err == nil {
+		t.Error("no error changing to a file")
+	}
+
+	echo := sh.Shell(`echo "$A $(basename "$(pwd)")"`)
+	if out, err := base.Run(echo); err != nil || out != "base "+filepath.Base(dir)+"\n" {
+		t.Errorf("base: got %q, %v", out, err)
+	}
+	if out, err := branch.Run(echo); err != nil || out != "branch sub\n" {
+		t.Errorf("branch: got %q, %v", out, err)
+	}
+}
@@ -16,11 +16,27 @@
 //
 //	// output:
 //	// 1
+//
+// Some common commands, such as echo, cat, grep, and wc, also have builtin
+// versions written in Go, which work even where the programs themselves
+// aren't installed:
+//
+//	fmt.Print(sh.Pipe(sh.Echo("Hi there!"), sh.CountWords()))
+//
+//	// output:
+//	// 2
 package sh
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"time"
 
 	"labix.org/v2/pipe"
 )
@@ -33,7 +49,7 @@ import (
 // returned function is run, allowing you to pre-set some common arguments.
 func Cmd(name string, args0 ...string) func(args ...string) Executable {
 	return func(args1 ...string) Executable {
-		return Executable{pipe.Exec(name, append(args0, args1...)...)}
+		return Command(name, Args(args0...), Args(args1...))
 	}
 }
 
@@ -46,45 +62,597 @@ func Cmd(name string, args0 ...string) func(args ...string) Executable {
 // returned function is run, allowing you to pre-set some common arguments.
 func Runner(name string, args0 ...string) func(args ...string) (string, error) {
 	return func(args1 ...string) (string, error) {
-		return Executable{pipe.Exec(name, append(args0, args1...)...)}.Run()
+		return Command(name, Args(args0...), Args(args1...)).Run()
 	}
 }
 
 // Dump returns an excutable that will read the given file and dump its contents
 // as the Executable's stdout.
 func Dump(filename string) Executable {
-	return Executable{pipe.ReadFile(filename)}
+	return Cat(filename)
 }
 
 // Read returns an executable that will read from the given reader and use it as
 // the Executable's stdout.
 func Read(r io.Reader) Executable {
-	return Executable{pipe.Read(r)}
+	return Func(func(_ io.Reader, w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// Source returns an Executable that runs cmd, such as a Pipe built elsewhere,
+// with no stdin, as the source of the data for the stages after it:
+//
+//	logs := sh.Pipe(journalctl("-o", "cat"), sh.Grep("ERROR"))
+//	sh.Pipe(sh.Source(logs), sh.Uniq(sh.UniqCount()), sh.Head(10))
+//
+// As with any stage of a Pipe, the output of cmd is streamed to the next
+// stage as it is written, never held in memory whole, so cmd may write
+// without end.  Unlike cmd on its own, the result never reads the stdin it
+// is given, so it can't take the input of the Pipe meant for a later stage.
+// It should be the first stage of a Pipe, since the output of any stage
+// before it would go unread.
+func Source(cmd Executable) Executable {
+	return cmd.WithStdin(nil)
+}
+
+// Bytes returns an executable that writes b to its stdout.  Unlike Read of a
+// bytes.Reader, it writes all of b each time it is run.
+func Bytes(b []byte) Executable {
+	return Func(func(_ io.Reader, w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// Lines returns an executable that writes each of lines to its stdout,
+// followed by a newline, to feed a Go slice through a Pipe.  With no lines,
+// its stdout is empty.
+func Lines(lines ...string) Executable {
+	return writeRecords(lines, '\n')
+}
+
+// Lines0 works like Lines, but ends each record with a NUL byte rather than a
+// newline, like find -print0, for records such as file names that may hold
+// newlines.
+func Lines0(records ...string) Executable {
+	return writeRecords(records, 0)
+}
+
+// writeRecords returns an executable that writes each of recs to its stdout,
+// followed by delim.
+func writeRecords(recs []string, delim byte) Executable {
+	return Func(func(_ io.Reader, w io.Writer) error {
+		for _, rec := range recs {
+			if err := writeRecord(w, rec, delim, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ToFile returns an executable that writes its stdin to the given file,
+// creating it if necessary and truncating it if it already exists, like > in
+// the shell.  Its own stdout is empty, so it is meant to be the last stage of a
+// Pipe.
+func ToFile(filename string) Executable {
+	return Executable{pipe.WriteFile(filename, 0666)}
+}
+
+// AppendFile returns an executable that appends its stdin to the given file,
+// creating it if necessary, like >> in the shell.  Its own stdout is empty, so
+// it is meant to be the last stage of a Pipe.
+func AppendFile(filename string) Executable {
+	return Executable{pipe.AppendFile(filename, 0666)}
+}
+
+// Discard returns an executable that reads its stdin and throws it away, like
+// > /dev/null in the shell.  As the last stage of a Pipe, it keeps the output
+// of the stages before it from being kept in memory when only their errors
+// matter, and errors from those stages are still returned.
+func Discard() Executable {
+	return Func(func(r io.Reader, _ io.Writer) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+}
+
+// Tee returns an executable that copies its stdin to its stdout unchanged,
+// writing a copy of everything to w as it goes, like tee in the shell.  An
+// error writing to w stops the stream and is returned as the Executable's
+// error, unless w is wrapped with TeeContinue.
+func Tee(w io.Writer) Executable {
+	return TeeAll(w)
+}
+
+// TeeAll works like Tee, but writes a copy of everything to each of writers,
+// in turn, such as to archive the output of a stage and show it at once:
+//
+//	sh.Pipe(build, sh.TeeAll(logFile, os.Stderr), sh.Grep("FAIL"))
+//
+// An error writing to any of them stops the stream, unless that writer is
+// wrapped with TeeContinue.
+func TeeAll(writers ...io.Writer) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTask(s, func(_ context.Context, s *pipe.State) error {
+			tw := &teeWriter{writers: append([]io.Writer{s.Stdout}, writers...)}
+			_, err := io.Copy(tw, stdin(s))
+			if err := pipeError(err); err != nil {
+				return err
+			}
+			return errors.Join(tw.errs...)
+		})
+	}}
+}
+
+// TeeContinue wraps w so that if writing to it fails, Tee or TeeAll stops
+// writing to it but goes on copying the stream to stdout and its other
+// writers, for a copy that is nice to have but not worth stopping for, like
+// one shown on a terminal that may go away.  The error is returned once the
+// stream ends.
+func TeeContinue(w io.Writer) io.Writer {
+	return continueWriter{w}
+}
+
+// continueWriter is a writer wrapped with TeeContinue.
+type continueWriter struct {
+	io.Writer
+}
+
+// teeWriter writes to each of writers in turn, like io.MultiWriter, except
+// that writers wrapped with TeeContinue are dropped when they fail, and their
+// errors are kept in errs.
+type teeWriter struct {
+	writers []io.Writer
+	errs    []error
+}
+
+func (tw *teeWriter) Write(p []byte) (int, error) {
+	for i, w := range tw.writers {
+		if w == nil {
+			continue
+		}
+		n, err := w.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err == nil {
+			continue
+		}
+		if _, ok := w.(continueWriter); !ok {
+			return n, err
+		}
+		tw.writers[i] = nil
+		tw.errs = append(tw.errs, err)
+	}
+	return len(p), nil
 }
 
 // Pipe connects the output of one Executable to the input of the next
 // Executable in the list.  The result is an Executable that, when run, returns
 // the output of the last Executable run, and any error it might have had.
 //
-// If any of the Executables fails, no further Executables are run, and the
-// failing Executable's stderr and error are returned.
+// All of the Executables run at once, as in the shell, and the output of each
+// streams to the next as it is written.  Any Executable can be a stage,
+// including another Pipe, so pipelines built apart can be joined, as with
+// Source, without their output being collected in between.
+//
+// If one of the stages fails, its error is returned; if more than one fails,
+// the error joins all of their errors, in the order of the stages, with
+// errors.Join, so that none of them is lost.  Run with RunContext, every stage
+// is stopped once the context is done, and the error is the context's.
 func Pipe(cmds ...Executable) Executable {
-	ps := make([]pipe.Pipe, len(cmds))
-	for i, c := range cmds {
-		ps[i] = c.Pipe
-	}
-	return Executable{pipe.Line(ps...)}
+	return pipeline(nil, cmds)
+}
+
+// PipeContext works like Pipe, but every stage is stopped once ctx is done,
+// however the pipe is run, so that a stage that is stuck can't hold up the
+// rest forever.  The error is then ctx.Err(), such as context.Canceled or
+// context.DeadlineExceeded, rather than the errors of the stages that were
+// stopped.
+func PipeContext(ctx context.Context, cmds ...Executable) Executable {
+	p := Pipe(cmds...)
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(tctx context.Context, s *pipe.State) error {
+			tctx, cancel := context.WithCancel(tctx)
+			defer cancel()
+			stop := context.AfterFunc(ctx, cancel)
+			defer stop()
+			err := p.runIn(tctx, s)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		})
+	}}
 }
 
 // PipeWith functions like Pipe, but runs the first command with stdin as the
 // input.
 func PipeWith(stdin string, cmds ...Executable) Executable {
-	ps := make([]pipe.Pipe, len(cmds)+1)
-	ps[0] = pipe.Read(strings.NewReader(stdin))
-	for i, c := range cmds {
-		ps[i+1] = c.Pipe
+	return pipeline(func() Executable { return Read(strings.NewReader(stdin)) }, cmds)
+}
+
+// pipeline returns an Executable that runs cmds as the stages of a Pipe, fed
+// by the Executable returned by src, if src is not nil.  Each stage runs in a
+// task of its own, so that the errors of all the stages can be returned, where
+// pipe.Line on its own returns just the first.
+func pipeline(src func() Executable, cmds []Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			errs := make([]error, len(cmds))
+			ps := make([]pipe.Pipe, 0, len(cmds)+1)
+			if src != nil {
+				ps = append(ps, src().Pipe)
+			}
+			for i, c := range cmds {
+				i, c := i, Executable{stagePipe(c, i+1)}
+				ps = append(ps, func(s *pipe.State) error {
+					return addTask(s, func(ctx context.Context, s *pipe.State) error {
+						errs[i] = c.runIn(ctx, s)
+						return errs[i]
+					})
+				})
+			}
+			err := Executable{pipe.Line(ps...)}.runIn(ctx, s)
+			var failed []error
+			for _, e := range errs {
+				if e != nil {
+					failed = append(failed, e)
+				}
+			}
+			switch len(failed) {
+			case 0:
+				return err
+			case 1:
+				return failed[0]
+			}
+			return errors.Join(failed...)
+		})
+	}}
+}
+
+// stagePipe returns the pipe.Pipe of c, as stage n of a Pipe.
+func stagePipe(c Executable, n int) pipe.Pipe {
+	return withSettings(c.Pipe, func(st *settings) { st.stage = n })
+}
+
+// And returns an Executable that runs cmds one after another, stopping at the
+// first one that fails, like && in the shell.  Each command reads from the
+// same stdin and writes to the same stdout, and the error is that of the last
+// command run.
+func And(cmds ...Executable) Executable {
+	return sequence(cmds, func(err error) bool { return err != nil })
+}
+
+// Or returns an Executable that runs cmds one after another, stopping at the
+// first one that succeeds, like || in the shell.  Each command reads from the
+// same stdin and writes to the same stdout, and the error is that of the last
+// command run.
+func Or(cmds ...Executable) Executable {
+	return sequence(cmds, func(err error) bool { return err == nil })
+}
+
+// Seq returns an Executable that runs every one of cmds in order, whether or
+// not the ones before it failed, like ; in the shell.  Each command reads from
+// the same stdin and writes to the same stdout.  The error joins the errors of
+// all the commands that failed, using errors.Join.
+func Seq(cmds ...Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			var errs []error
+			for _, c := range cmds {
+				if err := c.runIn(ctx, s); err != nil {
+					errs = append(errs, err)
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}}
+}
+
+// Concat returns an Executable whose stdout is the stdout of each of cmds in
+// turn, like { a; b; c; } in the shell, for use as the source of a Pipe:
+//
+//	sh.Pipe(sh.Concat(header, body, footer), format)
+//
+// Unlike Pipe, the commands are not connected to one another; each is run once
+// the one before it has finished, with an empty stdin.  Unlike Seq, it stops at
+// the first command that fails, and the error is that command's.
+func Concat(cmds ...Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			for _, c := range cmds {
+				if err := c.runInWith(ctx, s, strings.NewReader(""), s.Stdout, s.Stderr); err != nil {
+					return err
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}}
+}
+
+// sequence returns an Executable that runs cmds in order until stop returns
+// true for the error of one of them.
+func sequence(cmds []Executable, stop func(err error) bool) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			var err error
+			for _, c := range cmds {
+				err = c.runIn(ctx, s)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if stop(err) {
+					break
+				}
+			}
+			return err
+		})
+	}}
+}
+
+// WithTimeout returns an Executable that runs cmd, killing it if it hasn't
+// finished after d has elapsed, in which case the error is a *TimeoutError.
+// Processes that cmd started, such as those of a shell script, are not
+// waited for then, even if they still hold its output open.  When used as a
+// stage of a Pipe, only that stage is subject to the timeout, and since such
+// processes can still keep the next stage waiting for its input to end, use
+// WithProcessGroup to stop them too.
+func WithTimeout(d time.Duration, cmd Executable) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, []Executable{cmd}, func(ctx context.Context, s *pipe.State) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			err := cmd.runIn(ctx, s)
+			if err == context.DeadlineExceeded {
+				return &TimeoutError{Timeout: d}
+			}
+			return err
+		})
+	}}
+}
+
+// TimeoutError is the error returned when a command created by WithTimeout is
+// killed for running too long.
+type TimeoutError struct {
+	// Timeout is how long the command was allowed to run.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("command timed out after %v", e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, so that errors.Is works on a
+// TimeoutError as it would on the error from a context's deadline.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithIdleTimeout returns an Executable that runs c, killing it if it goes d
+// without writing anything to its stdout or stderr, in which case the error
+// is an *IdleTimeoutError.  Unlike WithTimeout, it puts no limit on how long c
+// runs in all, as long as it keeps writing, so it catches a download that has
+// hung without cutting short one that is merely slow.  Time spent waiting for
+// a write to be taken, such as by the next stage of a Pipe, doesn't count as
+// idle, but time spent waiting for stdin does.
+func (c Executable) WithIdleTimeout(d time.Duration) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, []Executable{c}, func(ctx context.Context, s *pipe.State) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			idle := newIdleTimer(d, cancel)
+			defer idle.stop()
+			stdout := &idleWriter{w: s.Stdout, idle: idle}
+			stderr := stdout
+			if !sameWriter(s.Stdout, s.Stderr) {
+				stderr = &idleWriter{w: s.Stderr, idle: idle}
+			}
+			err := c.runInWith(ctx, s, s.Stdin, stdout, stderr)
+			if idle.fired() {
+				return &IdleTimeoutError{Timeout: d}
+			}
+			return err
+		})
+	}}
+}
+
+// IdleTimeoutError is the error returned when a command run with
+// WithIdleTimeout is killed for going too long without writing anything.
+type IdleTimeoutError struct {
+	// Timeout is how long the command was allowed to go without writing.
+	Timeout time.Duration
+}
+
+func (e *IdleTimeoutError) Error() string {
+	return fmt.Sprintf("command wrote nothing for %v", e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, as TimeoutError's does.
+func (e *IdleTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// idleTimer calls cancel once d has passed since the last write, not counting
+// the time that writes take.
+type idleTimer struct {
+	mu      sync.Mutex
+	d       time.Duration
+	t       *time.Timer
+	writing int
+	done    bool
+	cancel  func()
+}
+
+func newIdleTimer(d time.Duration, cancel func()) *idleTimer {
+	it := &idleTimer{d: d, cancel: cancel}
+	it.t = time.AfterFunc(d, it.fire)
+	return it
+}
+
+func (it *idleTimer) fire() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.writing == 0 {
+		it.done = true
+		it.cancel()
+	}
+}
+
+// pause stops the timer while a write is going on.
+func (it *idleTimer) pause() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.writing++
+	it.t.Stop()
+}
+
+// resume starts the timer again once no write is going on.
+func (it *idleTimer) resume() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.writing--; it.writing == 0 && !it.done {
+		it.t.Reset(it.d)
+	}
+}
+
+// fired reports whether the timer ran out.
+func (it *idleTimer) fired() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.done
+}
+
+func (it *idleTimer) stop() {
+	it.t.Stop()
+}
+
+// idleWriter writes to w, holding off its idle timer as it does.
+type idleWriter struct {
+	w    io.Writer
+	idle *idleTimer
+}
+
+func (iw *idleWriter) Write(p []byte) (int, error) {
+	iw.idle.pause()
+	defer iw.idle.resume()
+	return iw.w.Write(p)
+}
+
+// WithMaxOutput returns an Executable that runs c, killing it if it writes more
+// than n bytes to its stdout and stderr together, in which case the error is
+// an *OutputLimitError.  The first n bytes of output are still written, so
+// what the command was doing can be seen.  This guards against commands that
+// write without end, which would otherwise fill memory when their output is
+// collected, as Run does.
+func (c Executable) WithMaxOutput(n int64) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, []Executable{c}, func(ctx context.Context, s *pipe.State) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			limit := &outputLimit{left: n, cancel: cancel}
+			stdout := &limitWriter{w: s.Stdout, limit: limit}
+			stderr := stdout
+			if !sameWriter(s.Stdout, s.Stderr) {
+				stderr = &limitWriter{w: s.Stderr, limit: limit}
+			}
+			err := c.runInWith(ctx, s, s.Stdin, stdout, stderr)
+			if limit.exceeded() {
+				return &OutputLimitError{Limit: n}
+			}
+			return err
+		})
+	}}
+}
+
+// OutputLimitError is the error returned when a command run with
+// WithMaxOutput is killed for writing too much.
+type OutputLimitError struct {
+	// Limit is the number of bytes the command was allowed to write.
+	Limit int64
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("command output exceeded limit of %d bytes", e.Limit)
+}
+
+// outputLimit counts down the bytes that may still be written, and calls
+// cancel once more is written.
+type outputLimit struct {
+	mu     sync.Mutex
+	left   int64
+	over   bool
+	cancel func()
+}
+
+// take returns how many of n bytes may be written, and uses them up.
+func (l *outputLimit) take(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if int64(n) > l.left {
+		n = int(l.left)
+		l.over = true
+		l.cancel()
+	}
+	l.left -= int64(n)
+	return n
+}
+
+// exceeded reports whether more was written than the limit allows.
+func (l *outputLimit) exceeded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.over
+}
+
+// limitWriter writes to w as long as its limit allows, and fails after.
+type limitWriter struct {
+	w     io.Writer
+	limit *outputLimit
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	allowed := lw.limit.take(len(p))
+	n, err := lw.w.Write(p[:allowed])
+	if err == nil && n < len(p) {
+		err = errOutputLimit
 	}
-	return Executable{pipe.Line(ps...)}
+	return n, err
+}
+
+// errOutputLimit is the error from writing past the limit of a limitWriter.
+var errOutputLimit = errors.New("output limit exceeded")
+
+// MustRun runs cmd with the given string as standard input and returns its
+// stdout.  If the command fails, MustRun panics with an error that includes the
+// failing command line and whatever the command wrote to stderr.  This is meant
+// for scripts, where checking every error is just noise.
+func MustRun(cmd Executable, stdin string) string {
+	stdout, stderr := &buffer{}, &buffer{}
+	err := cmd.runTo(context.Background(), strings.NewReader(stdin), stdout, stderr)
+	if err == nil {
+		return stdout.String()
+	}
+	// An ExitError already names the command and holds the end of its
+	// stderr.
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Stderr != "" || stderr.String() == "" {
+		panic(err)
+	}
+	var rd redactor
+	if exitErr != nil {
+		rd = exitErr.redact
+	}
+	panic(fmt.Errorf("%w\n%s", err, rd.string(stderr.String())))
 }
 
 // Executable is a runnable construct.  You can run it by calling Run(), or by
@@ -95,27 +663,411 @@ type Executable struct {
 	pipe.Pipe
 }
 
+// WithDir returns an Executable that runs c in the directory dir.  A relative
+// dir is taken to be relative to the directory c would otherwise run in.
+func (c Executable) WithDir(dir string) Executable {
+	return Executable{func(s *pipe.State) error {
+		old := s.Dir
+		defer func() { s.Dir = old }()
+		s.Dir = s.Path(dir)
+		return c.Pipe(s)
+	}}
+}
+
+// WithEnv returns an Executable that runs c with env, a list of KEY=VALUE
+// pairs, as its environment.  The environment of the current process is not
+// inherited; use WithExtraEnv to add to it instead.
+func (c Executable) WithEnv(env ...string) Executable {
+	return Executable{func(s *pipe.State) error {
+		old := s.Env
+		defer func() { s.Env = old }()
+		s.Env = append([]string{}, env...)
+		return c.Pipe(s)
+	}}
+}
+
+// WithExtraEnv returns an Executable that runs c with env, a list of KEY=VALUE
+// pairs, added to the environment it would otherwise inherit.  Variables in
+// env replace inherited variables of the same name.
+func (c Executable) WithExtraEnv(env ...string) Executable {
+	return Executable{func(s *pipe.State) error {
+		old := s.Env
+		defer func() { s.Env = old }()
+		s.Env = append([]string(nil), s.Env...)
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			s.SetEnvVar(k, v)
+		}
+		return c.Pipe(s)
+	}}
+}
+
+// WithCleanEnv returns an Executable that runs c with an empty environment,
+// inheriting nothing from the current process, for runs that must not depend
+// on the host they happen on.  It is the same as WithEnv with no variables.
+// Variables are then added with WithExtraEnv on c, which applies after it:
+//
+//	c.WithExtraEnv("LANG=C").WithCleanEnv()
+//
+// gives c only LANG.  The other way around, WithEnv or WithExtraEnv applied
+// to the result of WithCleanEnv have no effect, since the environment they set
+// is cleared before c runs.  Commands are still found using the PATH of the
+// current process, unless a PATH is added.
+func (c Executable) WithCleanEnv() Executable {
+	return c.WithEnv()
+}
+
+// WithStdin returns an Executable that runs c with r as its standard input, in
+// place of the stdin it is run with.  With os.Stdin, this hands the terminal
+// to a command that prompts the user, such as ssh or git commit:
+//
+//	sh.Cmd("git")("commit").WithStdin(os.Stdin).Run()
+//
+// An *os.File is given to the command as it is, so the command can tell that
+// it is reading a terminal.  In a Pipe, only the first stage should be given
+// a stdin this way, since the stdin of every other stage is the output of the
+// stage before it, which would then go unread.  Unless r can be read more
+// than once, as a terminal can, the Executable should only be run once.
+func (c Executable) WithStdin(r io.Reader) Executable {
+	return Executable{func(s *pipe.State) error {
+		old := s.Stdin
+		defer func() { s.Stdin = old }()
+		s.Stdin = r
+		return c.Pipe(s)
+	}}
+}
+
+// WithStreams returns an Executable that runs c with stdin, stdout and stderr
+// connected straight to the given streams, in place of those it is run with,
+// for full control of its plumbing.  A nil stream leaves that one as it was.
+// Output that goes to the streams is not captured, so Run, for instance,
+// returns only what went elsewhere, and its error:
+//
+//	_, err := tar("-czf", "-", "dir").WithStreams(nil, f, os.Stderr).Run()
+//
+// Applied to a Pipe, stdin goes to the first stage, and stdout comes from the
+// last one, while stderr is shared by all stages.  As with WithStdin, only the
+// first stage of a Pipe should be given a stdin this way, and only the last
+// a stdout.
+func (c Executable) WithStreams(stdin io.Reader, stdout, stderr io.Writer) Executable {
+	return Executable{func(s *pipe.State) error {
+		oldIn, oldOut, oldErr := s.Stdin, s.Stdout, s.Stderr
+		defer func() { s.Stdin, s.Stdout, s.Stderr = oldIn, oldOut, oldErr }()
+		if stdin != nil {
+			s.Stdin = stdin
+		}
+		if stdout != nil {
+			s.Stdout = stdout
+		}
+		if stderr != nil {
+			s.Stderr = stderr
+		}
+		return c.Pipe(s)
+	}}
+}
+
+// WithStderr returns an Executable that runs c with its stderr going straight
+// to w as it is written, such as to a log file, rather than wherever it would
+// have gone otherwise.  Unlike with WithStreams, stderr then goes only to w:
+// the ExitError of a command that fails doesn't keep the end of it, and its
+// message names the file if w is a file, or says that stderr was redirected.
+func (c Executable) WithStderr(w io.Writer) Executable {
+	return c.WithStreams(nil, nil, &redirectedStderr{w: w})
+}
+
+// redirectedStderr is the stderr given to WithStderr, which commands write to
+// directly rather than also keeping the end of it for their errors.
+type redirectedStderr struct {
+	w io.Writer
+}
+
+func (r *redirectedStderr) Write(p []byte) (int, error) {
+	return r.w.Write(p)
+}
+
+// TeeStderr returns an Executable that runs c with a copy of its stderr
+// written to w as it is produced, such as to show the progress of a long
+// command on a terminal.  The stderr is still returned as it would be without
+// TeeStderr, and kept in the ExitError of a command that fails.  Writes to w
+// are serialized, so w may be shared by the stages of a Pipe.
+func (c Executable) TeeStderr(w io.Writer) Executable {
+	sw := &syncWriter{w: w}
+	return Executable{func(s *pipe.State) error {
+		old := s.Stderr
+		defer func() { s.Stderr = old }()
+		s.Stderr = io.MultiWriter(s.Stderr, sw)
+		return c.Pipe(s)
+	}}
+}
+
+// syncWriter serializes writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
+
 // RunWith executes the command with the given string as standard input, and
 // returns stdout and a nil error on success, or stderr and a non-nil error on
 // failure.
 func (c Executable) RunWith(stdin string) (string, error) {
-	out, err := pipe.CombinedOutput(
-		pipe.Line(pipe.Read(strings.NewReader(stdin)), c.Pipe),
-	)
-	return string(out), err
+	return c.RunContext(context.Background(), stdin)
+}
+
+// RunReader works like RunWith, but reads standard input from r as the command
+// runs, rather than from a string held in memory.  If r is an *os.File, the
+// command reads from the file directly.
+func (c Executable) RunReader(r io.Reader) (string, error) {
+	return c.run(context.Background(), r)
+}
+
+// RunFrom executes the command with the stdout of src as its standard input,
+// and returns the same as RunWith would.  The output of src is streamed into
+// the command as it is produced, so this is the same as running
+// Pipe(src, c).
+func (c Executable) RunFrom(src Executable) (string, error) {
+	return Pipe(src, c).Run()
+}
+
+// RunContext works like RunWith, but kills the command if ctx is cancelled or
+// its deadline passes before the command finishes.  In that case the output
+// produced so far is returned along with ctx.Err(), without waiting for
+// processes the command started, such as those of a shell script, to close
+// the output they may still hold open.  If ctx comes from WithRunner, the
+// commands are run by its CommandRunner, and if it comes from WithLogger, they
+// are logged to its logger.
+func (c Executable) RunContext(ctx context.Context, stdin string) (string, error) {
+	return c.run(ctx, strings.NewReader(stdin))
+}
+
+// CombinedOutput executes the command with the given string as standard input,
+// and returns its stdout and stderr merged into one string, like 2>&1 in the
+// shell, along with the error if any.  Output written by a single command
+// keeps the order it was written in, since both streams share one pipe.
+func (c Executable) CombinedOutput(stdin string) (string, error) {
+	return c.run(context.Background(), strings.NewReader(stdin))
+}
+
+// DividedRun executes the command with the given string as standard input, and
+// returns what it wrote to stdout and to stderr separately, whether or not it
+// succeeded, along with the error if any.
+func (c Executable) DividedRun(stdin string) (stdout string, stderr string, err error) {
+	outb, errb := &buffer{}, &buffer{}
+	err = c.runTo(context.Background(), strings.NewReader(stdin), outb, errb)
+	return outb.String(), errb.String(), err
 }
 
 // Run executes the command and returns the combined stdout and stderr, and the
 // error if any.
 func (c Executable) Run() (string, error) {
-	out, err := pipe.CombinedOutput(c.Pipe)
-	return string(out), err
+	return c.run(context.Background(), nil)
+}
+
+// Success runs the Executable with the given string as standard input, and
+// reports whether it succeeded, like if in the shell.  Its stdout and stderr
+// are thrown away.
+func (c Executable) Success(stdin string) bool {
+	return c.runTo(context.Background(), strings.NewReader(stdin), io.Discard, io.Discard) == nil
+}
+
+// Bytes runs the Executable and returns its standard output as raw bytes,
+// along with the error if any.  Unlike String, no conversion is done, so it is
+// safe to use for binary output.  Standard error is discarded.
+func (c Executable) Bytes() ([]byte, error) {
+	out := &buffer{}
+	err := c.runTo(context.Background(), nil, out, io.Discard)
+	return out.Bytes(), err
+}
+
+// Fields runs the Executable with the given string as standard input, and
+// returns its standard output split into words at runs of white space, as by
+// strings.Fields.  Standard error is discarded.  If the Executable fails, the
+// words it wrote are returned along with the error, so that a failure can be
+// told apart from output with no words.
+func (c Executable) Fields(stdin string) ([]string, error) {
+	out, err := c.stdout(stdin)
+	return strings.Fields(out), err
+}
+
+// SplitLines runs the Executable with the given string as standard input, and
+// returns the lines of its standard output, without their newlines.  Standard
+// error is discarded.  As with Fields, the lines are returned along with the
+// error if the Executable fails.
+func (c Executable) SplitLines(stdin string) ([]string, error) {
+	out, err := c.stdout(stdin)
+	if out == "" {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out, "\n"), "\n"), err
+}
+
+// SplitLines0 works like SplitLines, but splits the standard output into
+// records that end with a NUL byte rather than a newline, such as the output
+// of find -print0.
+func (c Executable) SplitLines0(stdin string) ([]string, error) {
+	out, err := c.stdout(stdin)
+	if out == "" {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out, "\x00"), "\x00"), err
+}
+
+// FirstLine runs the Executable with the given string as standard input, and
+// returns the first line of its standard output, without its newline, for
+// commands like hostname whose output is one line.  Once the line has been
+// read, the Executable is stopped as closing a Reader stops it, so the rest of
+// a long output is never read, and whether the Executable would have failed
+// afterwards is not reported.  Empty output gives "" and no error.  If the
+// Executable fails before it writes a whole line, what it wrote is returned
+// along with the error.  Standard error is discarded.
+func (c Executable) FirstLine(stdin string) (string, error) {
+	r, err := c.reader(strings.NewReader(stdin))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// stdout runs the Executable with the given string as standard input, and
+// returns its standard output.
+func (c Executable) stdout(stdin string) (string, error) {
+	out := &buffer{}
+	err := c.runTo(context.Background(), strings.NewReader(stdin), out, io.Discard)
+	return out.String(), err
+}
+
+// Reader starts the Executable and returns a reader that streams its standard
+// output as it is produced.  Once the output is exhausted, an error from the
+// command is returned from Read in place of io.EOF.  Closing the reader kills
+// the command if it is still running, and waits for it to exit, but not for
+// processes it started that still hold its output open.  Standard error is
+// discarded.
+func (c Executable) Reader() (io.ReadCloser, error) {
+	return c.reader(nil)
+}
+
+// reader works like Reader, with stdin as the standard input.
+func (c Executable) reader(stdin io.Reader) (io.ReadCloser, error) {
+	if DryRun {
+		out, err := c.dryRun()
+		return io.NopCloser(strings.NewReader(out)), err
+	}
+	pr, pw := io.Pipe()
+	s := pipe.NewState(pw, io.Discard)
+	s.Stdin = stdin
+	if err := c.Pipe(s); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		pw.CloseWithError(s.RunTasks())
+		close(done)
+	}()
+	return &reader{PipeReader: pr, s: s, done: done}, nil
+}
+
+// reader is the io.ReadCloser returned by Executable.Reader.
+type reader struct {
+	*io.PipeReader
+	s    *pipe.State
+	done chan struct{}
+}
+
+func (r *reader) Close() error {
+	r.s.Kill()
+	r.PipeReader.Close()
+	<-r.done
+	return nil
+}
+
+// WriteTo runs the Executable, writing its standard output directly to w.  It
+// returns the number of bytes written and the error if any, so that an
+// Executable is an io.WriterTo.  Standard error is discarded.
+func (c Executable) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := c.runTo(context.Background(), nil, cw, io.Discard)
+	return cw.n, err
+}
+
+// countWriter counts the bytes written through it.  Writes are serialized,
+// since several tasks may share a stdout.
+type countWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+	n  int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// run executes the command with stdin as its standard input and returns the
+// combined stdout and stderr.
+func (c Executable) run(ctx context.Context, stdin io.Reader) (string, error) {
+	out := &buffer{}
+	err := c.runTo(ctx, stdin, out, out)
+	return out.String(), err
+}
+
+// runTo executes the command with the given standard input, output, and
+// error.
+func (c Executable) runTo(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+	if DryRun {
+		out, err := c.dryRun()
+		io.WriteString(stdout, out)
+		return err
+	}
+	s := pipe.NewState(stdout, stderr)
+	s.Stdin = stdin
+	p := c.Pipe
+	if r, ok := ctx.Value(runnerKey{}).(CommandRunner); ok {
+		p = withSettings(p, func(st *settings) { st.runner = r })
+	}
+	if lg, ok := ctx.Value(loggerKey{}).(*logger); ok {
+		p = withSettings(p, func(st *settings) { st.logger = lg })
+	}
+	if err := p(s); err != nil {
+		return err
+	}
+	return runTasks(ctx, s)
+}
+
+// runTasks runs the tasks of s, killing them if ctx is done first.  It always
+// waits for the tasks to exit, so that killed processes get reaped.
+func runTasks(ctx context.Context, s *pipe.State) error {
+	if ctx.Done() == nil {
+		return s.RunTasks()
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.RunTasks() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		s.Kill()
+		<-done
+		return ctx.Err()
+	}
 }
 
 // String runs the Executable and returns the standard output if the command
 // succeeds, or stderr if the command fails.  If stderr is empty on failure, the
 // Error() value of the error is returned. This is most useful for passing an
-// executable into a fmt.Print style function.
+// executable into a fmt.Print style function; use Output to check the error.
 func (c Executable) String() string {
 	s, err := c.Run()
 	if err == nil {
@@ -126,3 +1078,184 @@ func (c Executable) String() string {
 	}
 	return err.Error()
 }
+
+// Output runs the Executable and returns its standard output along with the
+// error if any, for when a failure must not be mistaken for output, as it can
+// be with String.  Standard error is discarded, but the end of it is kept in
+// an *ExitError.
+func (c Executable) Output() (string, error) {
+	return c.stdout("")
+}
+
+// Trimmed works like String, but with leading and trailing white space, such
+// as the newline most commands end their output with, removed.
+func (c Executable) Trimmed() string {
+	return strings.TrimSpace(c.String())
+}
+
+// ChompNewline returns an Executable that runs c with a single newline, \n or
+// \r\n, removed from the end of its stdout, if there is one there, like chomp
+// in Perl.  Unlike Trimmed, it leaves any other white space alone, which
+// matters when that is part of the output.  The stdout is still streamed;
+// only a newline at the end of what has been written so far is held back.
+func (c Executable) ChompNewline() Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, []Executable{c}, func(ctx context.Context, s *pipe.State) error {
+			w := &chompWriter{w: s.Stdout}
+			err := c.runInWith(ctx, s, s.Stdin, w, s.Stderr)
+			if werr := w.finish(); err == nil {
+				err = pipeError(werr)
+			}
+			return err
+		})
+	}}
+}
+
+// chompWriter is an io.Writer that writes to w all but a newline at the end of
+// what was written to it.
+type chompWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+	// held is a newline, or the \r of one, at the end of what was written.
+	held string
+}
+
+func (cw *chompWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	s := cw.held + string(p)
+	n := 0
+	switch {
+	case strings.HasSuffix(s, "\r\n"):
+		n = 2
+	case strings.HasSuffix(s, "\n"), strings.HasSuffix(s, "\r"):
+		n = 1
+	}
+	cw.held = s[len(s)-n:]
+	if _, err := io.WriteString(cw.w, s[:len(s)-n]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish writes anything held back that is not a newline.
+func (cw *chompWriter) finish() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.held != "\r" {
+		return nil
+	}
+	_, err := io.WriteString(cw.w, cw.held)
+	return err
+}
+
+// RunTrimmed works like Run, but with leading and trailing white space removed
+// from the output.
+func (c Executable) RunTrimmed() (string, error) {
+	out, err := c.Run()
+	return strings.TrimSpace(out), err
+}
+
+// runIn runs c with the stdin, stdout, stderr, dir and env of s, in a state of
+// its own so that it can be killed on its own when ctx is done.  ctx must come
+// from a task added with addTask.
+func (c Executable) runIn(ctx context.Context, s *pipe.State) error {
+	return c.runInWith(ctx, s, s.Stdin, s.Stdout, s.Stderr)
+}
+
+// runInWith works like runIn, but uses the given stdin, stdout and stderr in
+// place of those of s.
+func (c Executable) runInWith(ctx context.Context, s *pipe.State, stdin io.Reader, stdout, stderr io.Writer) error {
+	sub := pipe.NewState(stdout, stderr)
+	sub.Stdin = stdin
+	sub.Dir = s.Dir
+	sub.Env = s.Env
+	st, _ := ctx.Value(settingsKey{}).(settings)
+	if err := withSettings(c.Pipe, func(s *settings) { *s = st })(sub); err != nil {
+		return err
+	}
+	return runTasks(ctx, sub)
+}
+
+// stdin returns the standard input of s, or an empty reader if it has none.
+func stdin(s *pipe.State) io.Reader {
+	if s.Stdin == nil {
+		return strings.NewReader("")
+	}
+	return s.Stdin
+}
+
+// addTaskFor works like addTask, for a task that runs cmds.  In a dry run no
+// task will be run, so cmds are set up in s right away instead, to list their
+// commands.
+func addTaskFor(s *pipe.State, cmds []Executable, f func(ctx context.Context, s *pipe.State) error) error {
+	if settingsOf(s).commands == nil {
+		return addTask(s, f)
+	}
+	for _, c := range cmds {
+		if err := c.Pipe(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTask adds a task to s that runs f.  Killing the task cancels the context
+// passed to f.  The context also carries the settings of s, such as its
+// exec.Cmd hooks, so that Executables run by f with runIn get them too.
+func addTask(s *pipe.State, f func(ctx context.Context, s *pipe.State) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, settingsKey{}, settingsOf(s))
+	cleanEnv := s.Env != nil && len(s.Env) == 0
+	return s.AddTask(&task{f: f, ctx: ctx, cancel: cancel, cleanEnv: cleanEnv})
+}
+
+// settingsKey is the context key for the settings of the state of a task.
+type settingsKey struct{}
+
+// task is a pipe.Task that runs a function.
+type task struct {
+	f      func(ctx context.Context, s *pipe.State) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	// cleanEnv is whether the task was added with an empty environment,
+	// which AddTask copies as nil, the environment of the current process.
+	cleanEnv bool
+}
+
+func (t *task) Run(s *pipe.State) error {
+	defer t.cancel()
+	if t.cleanEnv && s.Env == nil {
+		s.Env = []string{}
+	}
+	return t.f(t.ctx, s)
+}
+
+func (t *task) Kill() {
+	t.cancel()
+}
+
+// buffer is a bytes.Buffer that is safe to write to from several goroutines,
+// since the stages of a pipe all run concurrently.
+type buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+func (b *buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -1,10 +1,24 @@
 package sh_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"log/slog"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/natefinch/sh"
 )
@@ -37,6 +51,18 @@ func ExampleCmd() {
 	// Hi there!
 }
 
+func ExampleCommand() {
+	opts := []sh.Option{sh.Dir("/"), sh.Timeout(time.Minute)}
+	pwd := sh.Command("pwd", opts...)
+	cat := sh.Command("cat", sh.Args("-n"), sh.Stdin(strings.NewReader("Hi there!\n")))
+
+	fmt.Print(pwd)
+	fmt.Print(cat)
+	// output:
+	// /
+	//      1	Hi there!
+}
+
 func ExampleRunner() {
 	echo := sh.Runner("echo")
 
@@ -50,6 +76,12 @@ func ExampleRunner() {
 	// <nil>
 }
 
+func ExampleShell() {
+	fmt.Print(sh.Shell("echo Hi there! | tr '[:lower:]' '[:upper:]'"))
+	// output:
+	// HI THERE!
+}
+
 func ExamplePipe() {
 	echo := sh.Cmd("echo")
 
@@ -65,15 +97,14 @@ func ExamplePipe() {
 }
 
 func ExamplePipeWith() {
-	upper := sh.Cmd("tr", "[:lower:]", "[:upper:]")
 	grep := sh.Cmd("grep")
 
-	fmt.Print(sh.PipeWith(SWCrawl, grep("far"), upper()))
+	fmt.Print(sh.PipeWith(SWCrawl, grep("far"), sh.ToUpper()))
 	// output:
 	// A LONG TIME AGO, IN A GALAXY FAR, FAR AWAY....
 }
 
-func Example_String() {
+func ExampleExecutable_String() {
 	echo := sh.Cmd("echo")
 
 	executable := echo("Hi there!")
@@ -86,6 +117,811 @@ func Example_String() {
 	// Hi there!
 }
 
+func ExampleExecutable_Trimmed() {
+	echo := sh.Cmd("echo")
+
+	fmt.Printf("%q\n", echo("  Hi there!").Trimmed())
+	// output:
+	// "Hi there!"
+}
+
+func ExampleExecutable_Output() {
+	shell := sh.Cmd("sh", "-c")
+
+	out, err := shell("echo partial; echo oops >&2; exit 3").Output()
+	fmt.Printf("%q\n", out)
+	fmt.Println(err)
+	// output:
+	// "partial\n"
+	// sh: "sh -c 'echo partial; echo oops >&2; exit 3'" exited 3: oops
+}
+
+func ExampleExecutable_RunTrimmed() {
+	echo := sh.Cmd("echo")
+
+	out, err := echo("Hi there!").RunTrimmed()
+	fmt.Printf("%q %v\n", out, err)
+	// output:
+	// "Hi there!" <nil>
+}
+
+func ExampleExecutable_ChompNewline() {
+	printf := sh.Cmd("printf")
+
+	fmt.Printf("%q\n", printf(`  id: 42 \n\n`).ChompNewline())
+	// output:
+	// "  id: 42 \n"
+}
+
+func TestChompNewline(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"abc\n", "abc"},
+		{"abc\r\n", "abc"},
+		{"abc", "abc"},
+		{"abc\r", "abc\r"},
+		{"a\nb\n\n", "a\nb\n"},
+		{"", ""},
+		{"\n", ""},
+	} {
+		out, err := sh.Cat().ChompNewline().RunWith(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != tt.want {
+			t.Errorf("chomping %q got %q, want %q", tt.in, out, tt.want)
+		}
+	}
+
+	// Newlines split across writes are still found.
+	out, err := sh.Cmd("sh", "-c")(`printf 'a\r'; sleep 0.01; printf '\n'`).ChompNewline().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a" {
+		t.Errorf("got %q, want %q", out, "a")
+	}
+}
+
+func ExampleExecutable_DividedRun() {
+	shell := sh.Cmd("sh", "-c")
+
+	stdout, stderr, err := shell("echo done; echo warning >&2").DividedRun("")
+	fmt.Print(stdout)
+	fmt.Print(stderr)
+	fmt.Print(err)
+	// output:
+	// done
+	// warning
+	// <nil>
+}
+
+func ExampleExecutable_Success() {
+	grep := sh.Cmd("grep")
+
+	if grep("-q", "Leia").Success(SWCrawl) {
+		fmt.Println("Leia is in it")
+	}
+	if !grep("-q", "Yoda").Success(SWCrawl) {
+		fmt.Println("Yoda is not")
+	}
+	// output:
+	// Leia is in it
+	// Yoda is not
+}
+
+func ExampleExecutable_Bytes() {
+	printf := sh.Cmd("printf")
+
+	b, err := printf(`\000\001\377`).Bytes()
+	fmt.Println(b)
+	fmt.Println(err)
+	// output:
+	// [0 1 255]
+	// <nil>
+}
+
+func ExampleExecutable_Fields() {
+	echo := sh.Cmd("echo")
+
+	words, err := echo(" one  two\tthree ").Fields("")
+	fmt.Printf("%q %v\n", words, err)
+	// output:
+	// ["one" "two" "three"] <nil>
+}
+
+func ExampleExecutable_SplitLines() {
+	grep := sh.Cmd("grep")
+
+	lines, err := grep("Empire").SplitLines(SWCrawl)
+	fmt.Printf("%q %v\n", lines, err)
+	// output:
+	// ["against the evil Galactic Empire." "to steal secret plans to the Empire's" "Pursued by the Empire's sinister agents,"] <nil>
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		out  string
+		want []string
+	}{
+		{"", nil},
+		{"\n", []string{""}},
+		{"a", []string{"a"}},
+		{"a\n\nb\n", []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		got, err := sh.Cat().SplitLines(tt.out)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("output %q: got %q, %v, want %q, nil", tt.out, got, err, tt.want)
+		}
+	}
+
+	got, err := sh.Shell("echo partial; exit 1").SplitLines("")
+	if err == nil || !reflect.DeepEqual(got, []string{"partial"}) {
+		t.Errorf("failing command: got %q, %v, want [partial] and an error", got, err)
+	}
+}
+
+func ExampleExecutable_Reader() {
+	echo := sh.Cmd("echo")
+
+	r, err := echo("Hi there!").Reader()
+	if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+	io.Copy(os.Stdout, r)
+	// output:
+	// Hi there!
+}
+
+func ExampleExecutable_WriteTo() {
+	echo := sh.Cmd("echo")
+
+	n, err := echo("Hi there!").WriteTo(os.Stdout)
+	fmt.Println(n, err)
+	// output:
+	// Hi there!
+	// 10 <nil>
+}
+
+func ExampleExecutable_RunReader() {
+	grep := sh.Cmd("grep")
+
+	name := "ExampleRunReaderTest"
+	f, cleanup := openTempFile(name, SWCrawl)
+	defer cleanup()
+
+	out, err := grep("far").RunReader(f)
+	fmt.Print(out)
+	fmt.Print(err)
+	// output:
+	// A long time ago, in a galaxy far, far away....
+	// <nil>
+}
+
+func ExampleExecutable_RunFrom() {
+	echo := sh.Cmd("echo")
+	upper := sh.Cmd("tr", "[:lower:]", "[:upper:]")
+
+	out, err := upper().RunFrom(echo("Hi there!"))
+	fmt.Print(out)
+	fmt.Print(err)
+	// output:
+	// HI THERE!
+	// <nil>
+}
+
+func ExampleAnd() {
+	echo := sh.Cmd("echo")
+	test := sh.Cmd("test")
+
+	// Equivalent of shell command:
+	// $ test -d / && echo directory
+	fmt.Print(sh.And(test("-d", "/"), echo("directory")))
+	fmt.Print(sh.And(test("-f", "/"), echo("file")))
+	// output:
+	// directory
+	// sh: "test -f /" exited 1
+}
+
+func ExampleOr() {
+	echo := sh.Cmd("echo")
+	test := sh.Cmd("test")
+
+	// Equivalent of shell command:
+	// $ test -f / || echo not a file
+	fmt.Print(sh.Or(test("-f", "/"), echo("not a file")))
+	// output:
+	// not a file
+}
+
+func ExampleSeq() {
+	echo := sh.Cmd("echo")
+	test := sh.Cmd("test")
+
+	// Equivalent of shell command:
+	// $ echo one; test -f /; echo two
+	out, err := sh.Seq(echo("one"), test("-f", "/"), echo("two")).Run()
+	fmt.Print(out)
+	fmt.Print(err)
+	// output:
+	// one
+	// two
+	// sh: "test -f /" exited 1
+}
+
+func ExampleConcat() {
+	echo := sh.Cmd("echo")
+	tr := sh.Cmd("tr")
+
+	// Equivalent of shell command:
+	// $ { echo header; echo body; echo footer; } | tr a-z A-Z
+	fmt.Print(sh.Pipe(sh.Concat(echo("header"), echo("body"), echo("footer")), tr("a-z", "A-Z")))
+	// output:
+	// HEADER
+	// BODY
+	// FOOTER
+}
+
+func TestConcatStops(t *testing.T) {
+	echo := sh.Cmd("echo")
+	cat := sh.Cmd("cat")
+
+	out, err := sh.Concat(echo("one"), sh.Cmd("false")(), echo("two")).Run()
+	if out != "one\n" {
+		t.Errorf("got output %q, want %q", out, "one\n")
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Name != "false" {
+		t.Errorf("got error %v, want false's", err)
+	}
+
+	// The commands do not read the stdin of the Concat.
+	out, err = sh.Concat(cat(), echo("done")).RunWith("ignored\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "done\n" {
+		t.Errorf("got output %q, want %q", out, "done\n")
+	}
+}
+
+func ExamplePipeContext() {
+	yes := sh.Cmd("yes")
+	sleep := sh.Cmd("sleep")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := sh.PipeContext(ctx, yes(), sleep("10"), sh.Head(1)).Run()
+	fmt.Println(err)
+	// output:
+	// context deadline exceeded
+}
+
+func TestRunContextStopsEveryStage(t *testing.T) {
+	stuck := sh.Func(func(r io.Reader, w io.Writer) error {
+		// Reads from r fail once the pipe is stopped.
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := sh.Pipe(sh.Cmd("sleep")("10"), stuck, sh.Cmd("cat")()).RunContext(ctx, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %v to stop", d)
+	}
+}
+
+func ExampleWithTimeout() {
+	sleep := sh.Cmd("sleep")
+
+	_, err := sh.WithTimeout(10*time.Millisecond, sleep("10")).Run()
+	fmt.Print(err)
+	// output:
+	// command timed out after 10ms
+}
+
+func ExampleExecutable_WithDir() {
+	pwd := sh.Cmd("pwd")
+
+	fmt.Print(pwd().WithDir("/"))
+	// output:
+	// /
+}
+
+func ExampleExecutable_WithEnv() {
+	env := sh.Cmd("env")
+
+	fmt.Print(env().WithEnv("GREETING=Hi there!"))
+	// output:
+	// GREETING=Hi there!
+}
+
+func ExampleExecutable_WithExtraEnv() {
+	shell := sh.Cmd("sh", "-c")
+
+	fmt.Print(shell(`echo $GREETING; test -n "$PATH"`).WithExtraEnv("GREETING=Hi there!"))
+	// output:
+	// Hi there!
+}
+
+func ExampleExecutable_TeeStderr() {
+	build := sh.Shell("echo compiling >&2; echo built")
+
+	out, errOut, err := build.TeeStderr(os.Stdout).DividedRun("")
+	fmt.Printf("%q %q %v\n", out, errOut, err)
+	// output:
+	// compiling
+	// "built\n" "compiling\n" <nil>
+}
+
+func TestTeeStderrKeptInError(t *testing.T) {
+	var live bytes.Buffer
+	_, err := sh.Shell("echo oops >&2; exit 1").TeeStderr(&live).Run()
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Stderr != "oops\n" {
+		t.Errorf("got %#v, want an *sh.ExitError with the stderr", err)
+	}
+	if live.String() != "oops\n" {
+		t.Errorf("copied %q to the writer, want %q", live.String(), "oops\n")
+	}
+}
+
+func ExampleExecutable_CombinedOutput() {
+	shell := sh.Cmd("sh", "-c")
+
+	out, err := shell("echo building; echo warning >&2; echo done").CombinedOutput("")
+	fmt.Print(out)
+	fmt.Print(err)
+	// output:
+	// building
+	// warning
+	// done
+	// <nil>
+}
+
+func ExampleExecutable_WithTrace() {
+	echo := sh.Cmd("echo")
+	grep := sh.Cmd("grep", "-o")
+
+	out, err := sh.Pipe(echo("Hi there!"), grep("Hi")).WithTrace(os.Stdout).Run()
+	fmt.Print(out)
+	fmt.Print(err)
+	// Unordered output:
+	// + echo 'Hi there!'
+	// + grep -o Hi
+	// Hi
+	// <nil>
+}
+
+func ExampleExecutable_WithTraceFormat() {
+	echo := sh.Cmd("echo")
+	wc := sh.Cmd("wc", "-c")
+
+	c := sh.Pipe(echo("Hi there!"), wc()).WithTraceFormat(os.Stdout, func(name string, args []string) string {
+		return fmt.Sprintf("$ %s (%d args)", name, len(args))
+	})
+	c.Run()
+	// Unordered output:
+	// $ echo (1 args)
+	// $ wc (1 args)
+}
+
+func ExampleExecutable_WithRedact() {
+	curl := sh.Cmd("sh", "-c", `echo "bad login: $2" >&2; exit 22`, "curl")
+
+	pass := "hunter2"
+	_, err := curl("-u", "admin:"+pass).WithRedact(pass).WithTrace(os.Stdout).Output()
+	fmt.Println(err)
+	// output:
+	// + sh -c 'echo "bad login: $2" >&2; exit 22' curl -u 'admin:***'
+	// sh: "sh -c 'echo \"bad login: $2\" >&2; exit 22' curl -u 'admin:***'" exited 22: bad login: admin:***
+}
+
+func TestWithRedact(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := sh.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)), sh.LogStderr())
+	fail := sh.Cmd("sh", "-c")(`echo "token $1 rejected" >&2; exit 1`, "sh", "s3cr3t").WithRedact("s3cr3t", "")
+	_, err := fail.TeeStderr(io.Discard).RunContext(ctx, "")
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") || !strings.Contains(err.Error(), "token *** rejected") {
+		t.Errorf("error %q does not hide the secret", err)
+	}
+	if strings.Contains(buf.String(), "s3cr3t") || !strings.Contains(buf.String(), "***") {
+		t.Errorf("log %q does not hide the secret", buf.String())
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || !strings.Contains(exitErr.Stderr, "s3cr3t") {
+		t.Errorf("ExitError.Stderr should be left as it is")
+	}
+
+	sh.DryRun = true
+	defer func() { sh.DryRun = false }()
+	out, err := sh.Cmd("login")("--token", "s3cr3t").WithRedact("s3cr3t").Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "login --token '***'\n"; out != want {
+		t.Errorf("dry run got %q, want %q", out, want)
+	}
+}
+
+func ExampleExecutable_WithCleanEnv() {
+	env := sh.Cmd("env")
+
+	os.Setenv("LEAKY", "yes")
+	defer os.Unsetenv("LEAKY")
+	fmt.Print(env().WithExtraEnv("LANG=C").WithCleanEnv())
+	// output:
+	// LANG=C
+}
+
+func TestWithCleanEnv(t *testing.T) {
+	out, err := sh.Cmd("env")().WithCleanEnv().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("got environment %q, want none", out)
+	}
+}
+
+func ExampleExecutable_WithStdin() {
+	tr := sh.Cmd("tr")
+	sort := sh.Cmd("sort")
+
+	out, err := sh.Pipe(tr("a-z", "A-Z").WithStdin(strings.NewReader("b\na\n")), sort()).Run()
+	fmt.Print(out)
+	fmt.Println(err)
+	// output:
+	// A
+	// B
+	// <nil>
+}
+
+func ExampleExecutable_WithStreams() {
+	shell := sh.Cmd("sh", "-c")
+
+	var stdout, stderr bytes.Buffer
+	in := strings.NewReader("Hi\n")
+	out, err := shell("cat; echo warning >&2").WithStreams(in, &stdout, &stderr).Run()
+	fmt.Printf("%q %v\n", out, err)
+	fmt.Printf("%q %q\n", stdout.String(), stderr.String())
+	// output:
+	// "" <nil>
+	// "Hi\n" "warning\n"
+}
+
+func TestWithStreamsPipe(t *testing.T) {
+	tr := sh.Cmd("tr")
+	var stdout bytes.Buffer
+	in := strings.NewReader("b\na\n")
+	c := sh.Pipe(tr("a-z", "A-Z"), sh.Cmd("sort")(), sh.Cmd("sh", "-c")("cat; echo done >&2"))
+	out, err := c.WithStreams(in, &stdout, nil).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "A\nB\n" {
+		t.Errorf("got stdout %q, want %q", stdout.String(), "A\nB\n")
+	}
+	if out != "done\n" {
+		t.Errorf("got output %q, want the stderr left captured", out)
+	}
+}
+
+func ExampleExecutable_WithStderr() {
+	shell := sh.Cmd("sh", "-c")
+
+	var log bytes.Buffer
+	out, err := shell("echo result; echo progress >&2").WithStderr(&log).Run()
+	fmt.Printf("%q %q %v\n", out, log.String(), err)
+	// output:
+	// "result\n" "progress\n" <nil>
+}
+
+func TestWithStderrFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = sh.Cmd("sh", "-c")("echo oops >&2; exit 1").WithStderr(f).Run()
+	if want := fmt.Sprintf("exited 1 (stderr went to %s)", f.Name()); err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %v, want it to end with %q", err, want)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "oops\n" {
+		t.Errorf("file has %q, want %q", b, "oops\n")
+	}
+}
+
+func TestWithStderrWriter(t *testing.T) {
+	var log bytes.Buffer
+	_, err := sh.Cmd("sh", "-c")("echo oops >&2; exit 1").WithStderr(&log).Run()
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("got error %v, want an *sh.ExitError", err)
+	}
+	if exitErr.Stderr != "" || !strings.HasSuffix(err.Error(), "exited 1 (stderr was redirected)") {
+		t.Errorf("got error %q with stderr %q, want it to say stderr was redirected", err, exitErr.Stderr)
+	}
+	if log.String() != "oops\n" {
+		t.Errorf("writer got %q, want %q", log.String(), "oops\n")
+	}
+}
+
+func TestWithStdinFile(t *testing.T) {
+	name := "TestWithStdinFile"
+	f, cleanup := openTempFile(name, SWCrawl)
+	defer cleanup()
+
+	out, err := sh.Cmd("grep")("far").WithStdin(f).RunWith("ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "A long time ago, in a galaxy far, far away....\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func ExampleExecutable_Configure() {
+	greet := sh.Shell(`echo "$GREETING"`).Configure(func(cmd *exec.Cmd) {
+		cmd.Env = append(cmd.Env, "GREETING=hello")
+	})
+	out, err := greet.Run()
+	fmt.Print(out)
+	fmt.Print(err)
+	// output:
+	// hello
+	// <nil>
+}
+
+func ExampleExecutable_AcceptExitCodes() {
+	diff := sh.Cmd("diff")
+
+	// diff exits 1 when the files differ, which is the answer wanted here.
+	out, err := diff("/dev/null", "-").AcceptExitCodes(1).RunWith("new\n")
+	fmt.Print(strings.Contains(out, "> new"), err)
+	// output:
+	// true <nil>
+}
+
+func TestAcceptExitCodes(t *testing.T) {
+	failing := sh.Shell("exit 2").AcceptExitCodes(1)
+	var exitErr *sh.ExitError
+	if _, err := failing.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Errorf("exit 2 with 1 accepted: got %v, want exit status 2", err)
+	}
+	p := sh.Pipe(sh.Shell("exit 3"), sh.Shell("exit 1")).AcceptExitCodes(1, 3)
+	if _, err := p.Run(); err != nil {
+		t.Errorf("pipe with both codes accepted: got %v", err)
+	}
+}
+
+func ExampleExecutable_FailOnStderr() {
+	warn := sh.Shell("echo done; echo 'warning: config is deprecated' >&2")
+
+	out, err := warn.FailOnStderr().Output()
+	var stderrErr *sh.StderrError
+	if errors.As(err, &stderrErr) {
+		fmt.Printf("%q failed: %s", out, stderrErr.Stderr)
+	}
+	// output:
+	// "done\n" failed: warning: config is deprecated
+}
+
+func TestFailOnStderr(t *testing.T) {
+	quiet := sh.Cmd("echo")("hi")
+	noisy := sh.Shell("echo hi; echo oops >&2")
+
+	if _, err := quiet.FailOnStderr().Run(); err != nil {
+		t.Errorf("command that wrote nothing to stderr: got %v", err)
+	}
+	if _, err := noisy.Run(); err != nil {
+		t.Errorf("command without FailOnStderr: got %v", err)
+	}
+
+	// Run shares stderr with stdout, and stderr is still part of its output.
+	out, err := noisy.FailOnStderr().Run()
+	var stderrErr *sh.StderrError
+	if !errors.As(err, &stderrErr) || stderrErr.Stderr != "oops\n" || stderrErr.Stage != 0 {
+		t.Errorf("got error %#v, want an *sh.StderrError with stderr %q", err, "oops\n")
+	}
+	if !strings.Contains(out, "oops") {
+		t.Errorf("got output %q, want it to include stderr", out)
+	}
+
+	// Each stage of a Pipe is checked on its own.
+	_, errOut, err := sh.Pipe(noisy, sh.Cat()).FailOnStderr().DividedRun("")
+	if !errors.As(err, &stderrErr) || stderrErr.Stage != 1 || errOut != "oops\n" {
+		t.Errorf("got %q, %v, want an *sh.StderrError for stage 1", errOut, err)
+	}
+
+	// Accepted exit codes still fail if stderr was written to, and
+	// failures are still an *sh.ExitError.
+	if _, err := sh.Shell("echo oops >&2; exit 1").AcceptExitCodes(1).FailOnStderr().Run(); !errors.As(err, &stderrErr) {
+		t.Errorf("accepted exit code: got %v, want an *sh.StderrError", err)
+	}
+	var exitErr *sh.ExitError
+	if _, err := sh.Shell("echo oops >&2; exit 2").FailOnStderr().Run(); !errors.As(err, &exitErr) {
+		t.Errorf("failure: got %v, want an *sh.ExitError", err)
+	}
+
+	// Stderr going straight to a file is seen too.
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := noisy.FailOnStderr().WithStderr(f).Output(); !errors.As(err, &stderrErr) {
+		t.Errorf("stderr to a file: got %v, want an *sh.StderrError", err)
+	}
+}
+
+func ExampleExecutable_WithIdleTimeout() {
+	sleep := sh.Cmd("sleep")
+
+	// sleep writes nothing, so it is killed long before it would finish.
+	_, err := sleep("10").WithIdleTimeout(10 * time.Millisecond).Run()
+	fmt.Print(err)
+	// output:
+	// command wrote nothing for 10ms
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	// A command that keeps writing may run longer than its idle timeout.
+	chatty := sh.Shell("for i in 1 2 3 4 5 6; do echo $i; sleep 0.1; done")
+	out, err := chatty.WithIdleTimeout(2 * time.Second).Run()
+	if err != nil || out != "1\n2\n3\n4\n5\n6\n" {
+		t.Errorf("got %q, %v, want all of the output", out, err)
+	}
+	// Stderr counts as output too.
+	_, errOut, err := sh.Shell("for i in 1 2 3; do echo $i >&2; sleep 0.1; done").WithIdleTimeout(2 * time.Second).DividedRun("")
+	if err != nil || errOut != "1\n2\n3\n" {
+		t.Errorf("got stderr %q, %v, want all of it", errOut, err)
+	}
+
+	// The idle timeout is told apart from WithTimeout's.
+	start := time.Now()
+	_, err = sh.Pipe(sh.Cmd("echo")("hi"), sh.Cmd("sleep")("10").WithIdleTimeout(50*time.Millisecond)).Run()
+	var idleErr *sh.IdleTimeoutError
+	var timeoutErr *sh.TimeoutError
+	if !errors.As(err, &idleErr) || errors.As(err, &timeoutErr) || idleErr.Timeout != 50*time.Millisecond {
+		t.Errorf("got %v, want an *sh.IdleTimeoutError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want it to be context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %v, so the command was not killed", d)
+	}
+
+	// A write that is held up downstream doesn't count as idle time.
+	slow := sh.Func(func(r io.Reader, w io.Writer) error {
+		time.Sleep(300 * time.Millisecond)
+		_, err := io.Copy(w, r)
+		return err
+	})
+	out, err = sh.Pipe(sh.Cmd("seq")("100000").WithIdleTimeout(100*time.Millisecond), slow, sh.CountLines()).Run()
+	if err != nil || out != "100000\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "100000\n")
+	}
+}
+
+func ExampleExecutable_WithMaxOutput() {
+	yes := sh.Cmd("yes")
+
+	// yes never stops on its own, so this only returns because the limit
+	// is reached.
+	out, err := yes("y").WithMaxOutput(6).Run()
+	fmt.Printf("%q %v\n", out, err)
+	// output:
+	// "y\ny\ny\n" command output exceeded limit of 6 bytes
+}
+
+func TestWithMaxOutputSharedLimit(t *testing.T) {
+	c := sh.Shell("echo 12345; exec yes 67890 >&2").WithMaxOutput(8)
+	start := time.Now()
+	out, errOut, err := c.DividedRun("")
+	var limitErr *sh.OutputLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != 8 {
+		t.Errorf("got error %v, want an *sh.OutputLimitError", err)
+	}
+	if n := len(out) + len(errOut); n != 8 {
+		t.Errorf("got %d bytes of output (%q, %q), want 8", n, out, errOut)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %v, so the command was not killed", d)
+	}
+	if out, err := sh.Cmd("echo")("fits").WithMaxOutput(5).Run(); err != nil || out != "fits\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "fits\n")
+	}
+}
+
+func ExampleExitError() {
+	grep := sh.Cmd("grep")
+
+	// grep exits 1 when nothing matches, and 2 on a real error.
+	_, err := sh.PipeWith(SWCrawl, grep("Vader")).Run()
+	var exitErr *sh.ExitError
+	if errors.As(err, &exitErr) {
+		fmt.Print(exitErr.ExitCode())
+	}
+	// output:
+	// 1
+}
+
+func ExampleErrNotFound() {
+	for _, cmd := range []sh.Executable{sh.Cmd("thiswontwork")(), sh.Cmd("false")()} {
+		switch _, err := cmd.Run(); {
+		case errors.Is(err, sh.ErrNotFound):
+			fmt.Println("not installed")
+		case err != nil:
+			fmt.Println("failed")
+		}
+	}
+	// output:
+	// not installed
+	// failed
+}
+
+func TestErrNotFound(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	for _, c := range []struct {
+		name string
+		cmd  sh.Executable
+	}{
+		{"in PATH", sh.Cmd("thiswontwork")()},
+		{"at a path", sh.Cmd(missing)()},
+		{"relative to the directory", sh.Cmd("./missing")().WithDir(dir)},
+	} {
+		if _, err := c.cmd.Run(); !errors.Is(err, sh.ErrNotFound) {
+			t.Errorf("%s: running got %v, want sh.ErrNotFound", c.name, err)
+		}
+		if err := c.cmd.Check(); !errors.Is(err, sh.ErrNotFound) {
+			t.Errorf("%s: checking got %v, want sh.ErrNotFound", c.name, err)
+		}
+	}
+
+	// A path that doesn't exist keeps the error os/exec gave for it.
+	if _, err := sh.Cmd(missing)().Run(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want fs.ErrNotExist too", err)
+	}
+	// Commands that are found but fail, or can't start in their directory,
+	// aren't missing.
+	if _, err := sh.Cmd("false")().Run(); errors.Is(err, sh.ErrNotFound) {
+		t.Errorf("got sh.ErrNotFound for a command that exited 1: %v", err)
+	}
+	if _, err := sh.Cmd("true")().WithDir(missing).Run(); err == nil || errors.Is(err, sh.ErrNotFound) {
+		t.Errorf("got %v for a directory that doesn't exist, want an error that isn't sh.ErrNotFound", err)
+	}
+	// Nor are they when they set up the process itself, which os/exec
+	// leaves the directory to.
+	if _, err := sh.Cmd("true")().WithDir(missing).WithProcessGroup(true).Run(); err == nil || errors.Is(err, sh.ErrNotFound) {
+		t.Errorf("got %v for a directory that doesn't exist in a process group, want an error that isn't sh.ErrNotFound", err)
+	}
+}
+
+func ExampleMustRun() {
+	echo := sh.Cmd("echo")
+
+	fmt.Print(sh.MustRun(echo("Hi there!"), ""))
+	// output:
+	// Hi there!
+}
+
 func ExampleDump() {
 	grep := sh.Cmd("grep")
 
@@ -99,6 +935,58 @@ func ExampleDump() {
 	// A long time ago, in a galaxy far, far away....
 }
 
+func ExampleToFile() {
+	echo := sh.Cmd("echo")
+
+	name := "ExampleToFileTest"
+	defer os.Remove(name)
+
+	// Equivalent of shell commands
+	// $ echo Hi there! > ExampleToFileTest
+	// $ echo Bye now! >> ExampleToFileTest
+	sh.Pipe(echo("Hi there!"), sh.ToFile(name)).Run()
+	sh.Pipe(echo("Bye now!"), sh.AppendFile(name)).Run()
+	fmt.Print(sh.Dump(name))
+	// output:
+	// Hi there!
+	// Bye now!
+}
+
+func ExampleDiscard() {
+	seq := sh.Cmd("seq")
+	grep := sh.Cmd("grep")
+
+	out, err := sh.Pipe(seq("100000"), sh.Discard()).Run()
+	fmt.Printf("%q %v\n", out, err)
+	_, err = sh.Pipe(seq("10"), grep("nothing"), sh.Discard()).Run()
+	fmt.Println(err)
+	// output:
+	// "" <nil>
+	// sh: "grep nothing" (stage 2) exited 1
+}
+
+func ExampleTee() {
+	grep := sh.Cmd("grep")
+
+	var log bytes.Buffer
+	fmt.Print(sh.PipeWith(SWCrawl, sh.Tee(&log), grep("far")))
+	fmt.Print(log.String() == SWCrawl)
+	// output:
+	// A long time ago, in a galaxy far, far away....
+	// true
+}
+
+func ExampleTeeAll() {
+	grep := sh.Cmd("grep")
+
+	var archive, display bytes.Buffer
+	fmt.Print(sh.PipeWith(SWCrawl, sh.TeeAll(&archive, &display), grep("far")))
+	fmt.Print(archive.String() == SWCrawl, display.String() == SWCrawl)
+	// output:
+	// A long time ago, in a galaxy far, far away....
+	// true true
+}
+
 func ExampleRead() {
 	grep := sh.Cmd("grep")
 
@@ -111,6 +999,157 @@ func ExampleRead() {
 	// A long time ago, in a galaxy far, far away....
 }
 
+func ExampleSource() {
+	grep := sh.Cmd("grep")
+
+	// a pipeline built elsewhere, feeding another
+	rebels := sh.Pipe(sh.Lines(strings.Fields(SWCrawl)...), grep("-i", "^rebel"))
+	fmt.Print(sh.Pipe(sh.Source(rebels), sh.ToUpper(), sh.Sort(), sh.Uniq(sh.UniqCount())))
+	// output:
+	//       2 REBEL
+}
+
+func TestSourceStreams(t *testing.T) {
+	// yes never ends, so this only finishes if its output streams through
+	// both pipelines rather than being collected first.
+	yes := sh.Pipe(sh.Cmd("yes")("y"), sh.ToUpper())
+	out, err := sh.Pipe(sh.Source(yes), sh.Pipe(sh.Head(3), sh.CountLines())).Run()
+	if err != nil || strings.TrimSpace(out) != "3" {
+		t.Errorf("got %q, %v, want 3 lines", out, err)
+	}
+
+	// The stdin of the outer Pipe is left for the stage that wants it.
+	out, err = sh.Pipe(sh.Source(sh.Lines("a")), sh.Cat()).RunWith("ignored\n")
+	if err != nil || out != "a\n" {
+		t.Errorf("got %q, %v, want %q", out, err, "a\n")
+	}
+	out, err = sh.Source(sh.Cmd("cat")()).RunWith("ignored\n")
+	if err != nil || out != "" {
+		t.Errorf("got %q, %v, want no output for a command with no stdin", out, err)
+	}
+}
+
+func ExampleBytes() {
+	grep := sh.Cmd("grep")
+
+	fmt.Print(sh.Pipe(sh.Bytes([]byte(SWCrawl)), grep("far")))
+	// output:
+	// A long time ago, in a galaxy far, far away....
+}
+
+func TestBytesRunTwice(t *testing.T) {
+	c := sh.Pipe(sh.Bytes([]byte("a\nb\n")), sh.Cmd("wc", "-l")())
+	for i := 0; i < 2; i++ {
+		out, err := c.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(out) != "2" {
+			t.Errorf("run %d: got %q lines, want 2", i+1, out)
+		}
+	}
+}
+
+func ExampleLines() {
+	sort := sh.Cmd("sort")
+
+	fmt.Print(sh.Pipe(sh.Lines("pear", "apple", "fig"), sort()))
+	// output:
+	// apple
+	// fig
+	// pear
+}
+
+func ExampleLines0() {
+	files := []string{"notes.txt", "two\nlines.txt"}
+	records, err := sh.Pipe(sh.Lines0(files...), sh.MapLines0(strings.ToUpper)).SplitLines0("")
+	fmt.Printf("%q %v\n", records, err)
+	// output:
+	// ["NOTES.TXT" "TWO\nLINES.TXT"] <nil>
+}
+
+func ExampleExecutable_FirstLine() {
+	seq := sh.Cmd("seq")
+
+	// seq is stopped once it has written 1, long before it gets to a billion.
+	first, err := seq("1000000000").FirstLine("")
+	fmt.Println(first, err)
+	// output:
+	// 1 <nil>
+}
+
+func TestFirstLine(t *testing.T) {
+	for _, tt := range []struct {
+		out, want string
+	}{
+		{"", ""},
+		{"\n", ""},
+		{"a", "a"},
+		{"a\nb\n", "a"},
+	} {
+		got, err := sh.Cat().FirstLine(tt.out)
+		if err != nil || got != tt.want {
+			t.Errorf("output %q: got %q, %v, want %q, nil", tt.out, got, err, tt.want)
+		}
+	}
+
+	// The rest of the output isn't waited for; yes never stops on its own.
+	got, err := sh.Cmd("yes")("first").FirstLine("")
+	if got != "first" || err != nil {
+		t.Errorf("got %q, %v, want %q, nil", got, err, "first")
+	}
+	// Nor does a child of the command, which holds the output open too, hold
+	// up FirstLine.
+	start := time.Now()
+	got, err = sh.Shell("echo first; sleep 3").FirstLine("")
+	if got != "first" || err != nil {
+		t.Errorf("with a child process: got %q, %v, want %q, nil", got, err, "first")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("with a child process: FirstLine took %v", d)
+	}
+
+	// A command that fails before writing a line fails FirstLine.
+	var exitErr *sh.ExitError
+	if got, err := sh.Shell("printf partial; exit 3").FirstLine(""); got != "partial" || !errors.As(err, &exitErr) {
+		t.Errorf("got %q, %v, want %q and an *sh.ExitError", got, err, "partial")
+	}
+}
+
+func TestSplitLines0(t *testing.T) {
+	printf := sh.Cmd("printf")
+	for _, tt := range []struct {
+		format string
+		want   []string
+	}{
+		{`a\000b\nc\000`, []string{"a", "b\nc"}},
+		{`a\000b`, []string{"a", "b"}},
+		{``, nil},
+	} {
+		got, err := printf(tt.format).SplitLines0("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("printf %q: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestLinesEmpty(t *testing.T) {
+	out, err := sh.Pipe(sh.Lines(), sh.Cmd("wc", "-l")()).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out) != "0" {
+		t.Errorf("got %q lines, want 0", out)
+	}
+	out, err = sh.Lines().Run()
+	if err != nil || out != "" {
+		t.Errorf("got %q, %v, want empty output", out, err)
+	}
+}
+
 func TestString(t *testing.T) {
 	ex := sh.Cmd("thiswontwork")()
 	s := ex.String()
@@ -120,6 +1159,234 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestRunContext(t *testing.T) {
+	sleep := sh.Cmd("sleep")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sleep("10").RunContext(ctx, "")
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command was not killed, ran for %v", elapsed)
+	}
+}
+
+func TestWithTimeoutInPipe(t *testing.T) {
+	echo := sh.Cmd("echo")
+	cat := sh.Cmd("cat")
+	sleep := sh.Cmd("sleep")
+
+	_, err := sh.Pipe(echo("hi"), sh.WithTimeout(50*time.Millisecond, sleep("10")), cat()).Run()
+	var terr *sh.TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected a *TimeoutError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to be context.DeadlineExceeded")
+	}
+
+	out, err := sh.Pipe(echo("hi"), sh.WithTimeout(time.Minute, cat())).Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "hi\n" {
+		t.Errorf("expected %q, got %q", "hi\n", out)
+	}
+}
+
+func TestConfigureEachStage(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	p := sh.Pipe(sh.Cmd("echo")("hi"), sh.Cmd("cat")()).Configure(func(cmd *exec.Cmd) {
+		mu.Lock()
+		defer mu.Unlock()
+		names = append(names, cmd.Args[0])
+	})
+	out, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hi\n" {
+		t.Errorf("got output %q, want %q", out, "hi\n")
+	}
+	sort.Strings(names)
+	if want := []string{"cat", "echo"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fn called for %q, want %q", names, want)
+	}
+}
+
+func TestExitErrorMessage(t *testing.T) {
+	grep := sh.Cmd("grep")
+	fail := sh.Shell("echo oh no >&2; exit 2")
+
+	_, _, err := sh.Pipe(grep("-o", "Hi"), fail).DividedRun("Hi there!\n")
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("got error %v, want an *sh.ExitError", err)
+	}
+	if exitErr.Stage != 2 || exitErr.ExitCode() != 2 || exitErr.Stderr != "oh no\n" {
+		t.Errorf("got stage %d, exit code %d, stderr %q, want 2, 2, %q", exitErr.Stage, exitErr.ExitCode(), exitErr.Stderr, "oh no\n")
+	}
+	want := `sh: "/bin/sh -c 'echo oh no >&2; exit 2'" (stage 2) exited 2: oh no`
+	if runtime.GOOS != "windows" && err.Error() != want {
+		t.Errorf("got message %q, want %q", err, want)
+	}
+
+	// With Run, stderr is part of the output, so the error leaves it out.
+	out, err := grep("-E", "(").Run()
+	if !errors.As(err, &exitErr) || exitErr.Stage != 0 || exitErr.Stderr != "" || out == "" {
+		t.Errorf("got output %q, error %#v", out, err)
+	}
+}
+
+func TestPipeJoinsErrors(t *testing.T) {
+	first := sh.Shell("echo first failed >&2; exit 1")
+	second := sh.Shell("cat >/dev/null; echo second failed >&2; exit 2")
+	ok := sh.Cmd("cat")()
+
+	_, _, err := sh.Pipe(first, ok, second).DividedRun("")
+	if err == nil {
+		t.Fatal("no error")
+	}
+	msg := err.Error()
+	for _, want := range []string{"(stage 1) exited 1: first failed", "(stage 3) exited 2: second failed"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not contain %q", msg, want)
+		}
+	}
+	if strings.Index(msg, "stage 1") > strings.Index(msg, "stage 3") {
+		t.Errorf("error %q is not in stage order", msg)
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Stage != 1 {
+		t.Errorf("errors.As found %v, want the error of stage 1", exitErr)
+	}
+
+	// A lone failure is returned as it is.
+	_, err = sh.Pipe(ok, second).RunWith("")
+	if !errors.As(err, &exitErr) || err != error(exitErr) {
+		t.Errorf("got %#v, want an *sh.ExitError", err)
+	}
+}
+
+func TestPipeWithRunTwice(t *testing.T) {
+	c := sh.PipeWith("hi\n", sh.Cmd("cat")())
+	for i := 0; i < 2; i++ {
+		if out, err := c.Run(); err != nil || out != "hi\n" {
+			t.Errorf("run %d: got %q, %v, want %q, nil", i+1, out, err, "hi\n")
+		}
+	}
+}
+
+func TestWithDirMissing(t *testing.T) {
+	pwd := sh.Cmd("pwd")
+	dir := "/this/dir/does/not/exist"
+
+	for _, c := range []sh.Executable{pwd(), pwd().WithProcessGroup(true)} {
+		_, err := c.WithDir(dir).Run()
+		if err == nil {
+			t.Fatal("expected an error running in a missing directory")
+		}
+		if !strings.Contains(err.Error(), "chdir "+dir) {
+			t.Errorf("expected error to mention %q, got %q", dir, err)
+		}
+	}
+}
+
+func TestReaderCloseKills(t *testing.T) {
+	yes := sh.Cmd("yes")
+
+	r, err := yes().Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "y\ny\n" {
+		t.Errorf("expected %q, got %q", "y\ny\n", buf)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the command")
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTeeWriteError(t *testing.T) {
+	_, err := sh.PipeWith(SWCrawl, sh.Tee(errWriter{})).Run()
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("expected disk full error, got %v", err)
+	}
+}
+
+func TestTeeAllWriteError(t *testing.T) {
+	var before, after bytes.Buffer
+	_, err := sh.PipeWith(SWCrawl, sh.TeeAll(&before, errWriter{}, &after)).Run()
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("expected disk full error, got %v", err)
+	}
+	if after.Len() != 0 {
+		t.Errorf("wrote %q after the failed writer, want nothing", after.String())
+	}
+
+	// With TeeContinue, the stream carries on without the failed writer.
+	before.Reset()
+	out, err := sh.PipeWith(SWCrawl, sh.TeeAll(&before, sh.TeeContinue(errWriter{}), &after)).Run()
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("expected disk full error, got %v", err)
+	}
+	for name, got := range map[string]string{"stdout": out, "first writer": before.String(), "last writer": after.String()} {
+		if got != SWCrawl {
+			t.Errorf("got %q on %s, want all of the input", got, name)
+		}
+	}
+	if out, err := sh.PipeWith("hi\n", sh.Tee(sh.TeeContinue(io.Discard))).Run(); err != nil || out != "hi\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "hi\n")
+	}
+}
+
+func TestMustRunPanics(t *testing.T) {
+	ls := sh.Cmd("ls")
+	defer func() {
+		err, ok := recover().(error)
+		if !ok {
+			t.Fatal("expected MustRun to panic with an error")
+		}
+		var exitErr *sh.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("expected panic to wrap an *ExitError, got %v", err)
+		}
+		msg := err.Error()
+		if !strings.Contains(msg, "ls -l /no/such/file") {
+			t.Errorf("expected panic to include the command line, got %q", msg)
+		}
+		if n := strings.Count(msg, "No such file"); n != 1 {
+			t.Errorf("expected panic to include stderr once, got %q", msg)
+		}
+		if n := strings.Count(msg, "sh:"); n != 1 {
+			t.Errorf("expected one sh: prefix in the panic, got %q", msg)
+		}
+	}()
+	sh.MustRun(ls("-l", "/no/such/file"), "")
+}
+
 func openTempFile(name, content string) (f *os.File, cleanup func()) {
 	err := ioutil.WriteFile(name, []byte(content), 0777)
 	if err != nil {
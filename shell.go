@@ -0,0 +1,46 @@
+package sh
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"runtime"
+	"unicode/utf16"
+)
+
+// Shell returns an Executable that runs script with the platform's shell,
+// /bin/sh -c on Unix and cmd /c on Windows, for when shell features like
+// redirection or globbing are really needed.  On Windows the script is handed
+// to cmd exactly as written, so quote arguments containing spaces the way cmd
+// expects.
+//
+// The script is interpreted by the shell, so never build it from untrusted
+// input: anything the shell treats as syntax, like ; or $(...), will be run.
+func Shell(script string) Executable {
+	return Executable{shellPipe(script)}
+}
+
+// PowerShell returns an Executable that runs script with PowerShell:
+// powershell.exe on Windows, and pwsh everywhere else.  The script is passed
+// encoded, so it reaches PowerShell unchanged no matter what quotes or spaces
+// it contains.  An exit statement in the script sets the exit code reported by
+// ExitError.
+//
+// As with Shell, never build the script from untrusted input.
+func PowerShell(script string) Executable {
+	name := "pwsh"
+	if runtime.GOOS == "windows" {
+		name = "powershell"
+	}
+	return Executable{execPipe(name, "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(script))}
+}
+
+// encodePowerShell encodes script the way PowerShell's -EncodedCommand flag
+// expects: base64 of its UTF-16LE encoding.
+func encodePowerShell(script string) string {
+	u := utf16.Encode([]rune(script))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package sh
+
+import "labix.org/v2/pipe"
+
+// shellPipe returns a pipe.Pipe that runs script with /bin/sh.
+func shellPipe(script string) pipe.Pipe {
+	return execPipe("/bin/sh", "-c", script)
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package sh
+
+import (
+	"os/exec"
+	"syscall"
+
+	"labix.org/v2/pipe"
+)
+
+// shellPipe returns a pipe.Pipe that runs script with cmd.exe.
+//
+// cmd does not parse its command line the way os/exec quotes arguments, so
+// the command line is set directly.  With /s, cmd strips the outer quotes and
+// runs everything between them as written.  The script stays in the
+// arguments too, so that traces and errors show it.
+func shellPipe(script string) pipe.Pipe {
+	return withHooks(execPipe("cmd", "/d", "/s", "/c", script), func(cmd *exec.Cmd, _ redactor) func() {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.CmdLine = `cmd /d /s /c "` + script + `"`
+		return nil
+	})
+}
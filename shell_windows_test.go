@@ -0,0 +1,81 @@
+//go:build windows
+
+package sh_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func TestShellWindows(t *testing.T) {
+	out, err := sh.Shell(`echo "Hi there!"`).Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "\"Hi there!\"\r\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	_, err = sh.Shell("exit /b 3").Run()
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestShellWindowsConfigure(t *testing.T) {
+	var ran *exec.Cmd
+	runner := runnerFunc(func(ctx context.Context, cmd *exec.Cmd) error {
+		ran = cmd
+		return sh.ExecRunner{}.Run(ctx, cmd)
+	})
+	script := sh.Shell("exit /b 3").Configure(func(cmd *exec.Cmd) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	})
+	_, err := script.RunContext(sh.WithRunner(context.Background(), runner), "")
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "exit /b 3") {
+		t.Errorf("expected the error to show the script, got %q", err)
+	}
+	if want := []string{"cmd", "/d", "/s", "/c", "exit /b 3"}; !reflect.DeepEqual(ran.Args, want) {
+		t.Errorf("expected args %q, got %q", want, ran.Args)
+	}
+	if !ran.SysProcAttr.HideWindow {
+		t.Error("expected HideWindow set by Configure to be kept")
+	}
+}
+
+// runnerFunc is an sh.CommandRunner that calls itself.
+type runnerFunc func(ctx context.Context, cmd *exec.Cmd) error
+
+func (f runnerFunc) Run(ctx context.Context, cmd *exec.Cmd) error {
+	return f(ctx, cmd)
+}
+
+func TestPowerShellWindows(t *testing.T) {
+	out, err := sh.PowerShell(`Write-Output "Hi there!"`).Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "Hi there!\r\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	_, err = sh.PowerShell("exit 3").Run()
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+// Package shtest helps test code that uses package sh, by faking the commands
+// it runs instead of starting real processes.
+//
+//	fake := shtest.Install(t)
+//	fake.On("git rev-parse HEAD", shtest.Reply{Stdout: "abc123\n"})
+//
+//	// code under test
+//	out, err := sh.Cmd("git")("rev-parse", "HEAD").Run()
+//
+//	// out is "abc123\n", and fake.Calls() lists the command.
+//
+// A test of code that runs many commands can compare them all, with their
+// stdin, directories and environment, against a golden file:
+//
+//	fake.CheckGolden(t, "testdata/deploy.golden")
+//
+// Running the tests with SHTEST_UPDATE=1 in the environment writes the golden
+// files instead, from the commands that were run.
+package shtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+// Reply is the canned result of a faked command.
+type Reply struct {
+	// Stdout is written to the command's stdout.
+	Stdout string
+	// Stderr is written to the command's stderr.
+	Stderr string
+	// Err, if not nil, makes the command fail with this error.
+	Err error
+}
+
+// Call is a command that a Fake was asked to run.
+type Call struct {
+	// Name is the name of the command, as given to sh.Command or sh.Cmd.
+	Name string
+	// Args are the arguments of the command.
+	Args []string
+	// Dir is the directory the command would have run in.
+	Dir string
+	// Env is the environment the command would have run with.
+	Env []string
+	// Stdin is everything the command was given on stdin.
+	Stdin string
+}
+
+// String formats c for a transcript, as the command line, quoted as by
+// sh.QuoteAll and led by "$ ", followed by a line for each of its directory,
+// its environment, and its stdin, if it has one:
+//
+//	$ git commit -F -
+//	  dir: /src
+//	  env: +GIT_AUTHOR_NAME=bot
+//	  stdin: "release 1.2\n"
+//
+// So that the transcript is the same whatever machine it is made on, an Env
+// that keeps every variable of the environment of the current process, as
+// that of sh.WithExtraEnv does, is listed as its changes to it, each as
+// +K=V.  Any other Env was set from scratch, as by sh.WithEnv, and is listed
+// whole, each variable as K=V, or as (empty) if it has none.  Both are sorted
+// by name.  Stdin is quoted as by %q.
+func (c Call) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ %s\n", sh.QuoteAll(append([]string{c.Name}, c.Args...)...))
+	if c.Dir != "" {
+		fmt.Fprintf(&b, "  dir: %s\n", c.Dir)
+	}
+	if env := envString(c.Env); env != "" {
+		fmt.Fprintf(&b, "  env: %s\n", env)
+	}
+	if c.Stdin != "" {
+		fmt.Fprintf(&b, "  stdin: %q\n", c.Stdin)
+	}
+	return b.String()
+}
+
+// envString formats env for Call.String, or returns "" if env is nil or is the
+// environment of the current process.
+func envString(env []string) string {
+	if env == nil {
+		return ""
+	}
+	cur := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		cur[k] = v
+	}
+	var changes []string
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		cv, ok := cur[k]
+		if !ok {
+			// A variable was dropped, so env is not based on ours.
+			vars := append([]string(nil), env...)
+			sort.Strings(vars)
+			if len(vars) == 0 {
+				return "(empty)"
+			}
+			return strings.Join(vars, " ")
+		}
+		if cv == v {
+			delete(cur, k)
+		}
+	}
+	for k, v := range cur {
+		changes = append(changes, "+"+k+"="+v)
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, " ")
+}
+
+// Fake is an sh.CommandRunner that records the commands it is asked to run
+// and replies to them with canned output, without running anything.  The
+// zero value is ready to use, and replies to every command with Default.  A
+// Fake is safe to use from several goroutines at once, as the stages of a Pipe
+// do.
+type Fake struct {
+	// Default is the reply to commands that have no reply set with On.
+	Default Reply
+
+	mu      sync.Mutex
+	replies map[string]Reply
+	calls   []Call
+}
+
+// Install makes f, a new Fake, run the commands of every Executable until the
+// end of the test, by setting sh.DefaultRunner.  Tests that use Install must
+// not run in parallel with other tests that run commands.
+func Install(t testing.TB) *Fake {
+	f := &Fake{}
+	old := sh.DefaultRunner
+	sh.DefaultRunner = f
+	t.Cleanup(func() { sh.DefaultRunner = old })
+	return f
+}
+
+// On sets the reply to the command whose command line is cmdline.  A command
+// line is the name and arguments of the command, separated by spaces and
+// quoted as by sh.QuoteAll, such as "grep -v 'hello world'".
+func (f *Fake) On(cmdline string, r Reply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.replies == nil {
+		f.replies = make(map[string]Reply)
+	}
+	f.replies[cmdline] = r
+}
+
+// Calls returns the commands f has been asked to run so far, in the order they
+// finished reading their stdin.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Transcript returns the commands f has been asked to run so far, each
+// formatted with Call.String, in the order of Calls.  Commands that run at
+// once, as the stages of a Pipe do, are listed in the order they finished
+// reading their stdin, which for a Pipe is the order of its stages.
+func (f *Fake) Transcript() string {
+	var b strings.Builder
+	for _, c := range f.Calls() {
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+// CheckGolden fails t if the Transcript of f is not the same as the contents
+// of the golden file at path.  If the environment variable SHTEST_UPDATE is
+// set to anything but the empty string, the Transcript is written to the file
+// instead.
+func (f *Fake) CheckGolden(t testing.TB, path string) {
+	t.Helper()
+	got := f.Transcript()
+	if os.Getenv("SHTEST_UPDATE") != "" {
+		if err := os.WriteFile(path, []byte(got), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with SHTEST_UPDATE=1 to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("commands run differ from %s:\n--- got\n%s--- want\n%s", path, got, want)
+	}
+}
+
+// Run records cmd and replies to it, after reading all of its stdin.
+func (f *Fake) Run(ctx context.Context, cmd *exec.Cmd) error {
+	c := Call{Name: cmd.Args[0], Args: cmd.Args[1:], Dir: cmd.Dir, Env: cmd.Env}
+	if cmd.Stdin != nil {
+		b, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		c.Stdin = string(b)
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+	r, ok := f.replies[sh.QuoteAll(cmd.Args...)]
+	if !ok {
+		r = f.Default
+	}
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cmd.Stdout != nil {
+		if _, err := io.WriteString(cmd.Stdout, r.Stdout); err != nil {
+			return err
+		}
+	}
+	if cmd.Stderr != nil {
+		if _, err := io.WriteString(cmd.Stderr, r.Stderr); err != nil {
+			return err
+		}
+	}
+	return r.Err
+}
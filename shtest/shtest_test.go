@@ -0,0 +1,118 @@
+package shtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/natefinch/sh"
+	"github.com/natefinch/sh/shtest"
+)
+
+func ExampleFake() {
+	fake := &shtest.Fake{}
+	fake.On("git rev-parse HEAD", shtest.Reply{Stdout: "abc123\n"})
+	old := sh.DefaultRunner
+	sh.DefaultRunner = fake
+	defer func() { sh.DefaultRunner = old }()
+
+	out, err := sh.Cmd("git")("rev-parse", "HEAD").Run()
+	fmt.Print(out)
+	fmt.Println(err)
+	fmt.Println(fake.Calls()[0].Args)
+	// output:
+	// abc123
+	// <nil>
+	// [rev-parse HEAD]
+}
+
+func TestInstall(t *testing.T) {
+	fake := shtest.Install(t)
+	fake.On("tr a-z A-Z", shtest.Reply{Stdout: "HI\n"})
+	fake.Default = shtest.Reply{Stdout: "hi\n"}
+
+	out, err := sh.Pipe(sh.Cmd("echo")("hi"), sh.Cmd("tr")("a-z", "A-Z")).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "HI\n" {
+		t.Errorf("got %q, want %q", out, "HI\n")
+	}
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2: %v", len(calls), calls)
+	}
+	for _, c := range calls {
+		if c.Name == "tr" && c.Stdin != "hi\n" {
+			t.Errorf("tr got stdin %q, want %q", c.Stdin, "hi\n")
+		}
+	}
+}
+
+func TestReplyErr(t *testing.T) {
+	fake := shtest.Install(t)
+	boom := errors.New("boom")
+	fake.On("make", shtest.Reply{Stderr: "no rule\n", Err: boom})
+
+	out, err := sh.Command("make").WithDir("/src").Run()
+	if !errors.Is(err, boom) {
+		t.Errorf("got error %v, want %v", err, boom)
+	}
+	if out != "no rule\n" {
+		t.Errorf("got output %q, want %q", out, "no rule\n")
+	}
+	want := []shtest.Call{{Name: "make", Args: []string{}, Dir: "/src", Env: fake.Calls()[0].Env}}
+	if got := fake.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %v, want %v", got, want)
+	}
+}
+
+func ExampleFake_Transcript() {
+	fake := &shtest.Fake{}
+	ctx := sh.WithRunner(context.Background(), fake)
+
+	git := sh.Cmd("git")
+	script := sh.And(
+		git("add", "-A").WithDir("/src"),
+		git("commit", "-F", "-").WithExtraEnv("GIT_AUTHOR_NAME=bot"),
+		sh.Cmd("make")("release").WithEnv("GOOS=linux"),
+	)
+	script.RunContext(ctx, "release 1.2\n")
+	fmt.Print(fake.Transcript())
+	// output:
+	// $ git add -A
+	//   dir: /src
+	//   stdin: "release 1.2\n"
+	// $ git commit -F -
+	//   env: +GIT_AUTHOR_NAME=bot
+	// $ make release
+	//   env: GOOS=linux
+}
+
+func TestCheckGolden(t *testing.T) {
+	fake := shtest.Install(t)
+	fake.On("echo hi", shtest.Reply{Stdout: "hi\n"})
+	sh.Pipe(sh.Cmd("echo")("hi"), sh.Cmd("tr")("a-z", "A-Z")).WithEnv("LANG=C").Run()
+	fake.CheckGolden(t, "testdata/pipe.golden")
+
+	// A missing golden file fails the test.
+	ft := &fakeT{TB: t}
+	fake.CheckGolden(ft, "testdata/missing.golden")
+	if !ft.failed {
+		t.Error("CheckGolden passed with no golden file")
+	}
+}
+
+// fakeT is a testing.TB that records whether it failed, rather than failing.
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) { t.failed = true }
+
+func (t *fakeT) Fatalf(format string, args ...any) { t.failed = true }
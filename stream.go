@@ -0,0 +1,134 @@
+package sh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+)
+
+// RunFunc runs the Executable with the given string as standard input, and
+// calls onLine with each line of its standard output as soon as the line is
+// written, for showing progress from long running commands.  onLine is called
+// on the calling goroutine, in order, with the line minus its newline.  A
+// final line with no newline is passed to onLine once the output ends.
+// Standard error is discarded.  RunFunc returns once the Executable has
+// finished, with its error if any.
+func (c Executable) RunFunc(stdin string, onLine func(line string)) error {
+	r, err := c.reader(strings.NewReader(stdin))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return forEachLine(r, func(line string, _ bool) error {
+		onLine(line)
+		return nil
+	})
+}
+
+// Scan runs the Executable with the given string as standard input, and reads
+// its standard output with a bufio.Scanner that splits it with split, calling
+// fn with each token as soon as it is read, on the calling goroutine.  A nil
+// split scans lines, as RunFunc does, and maxToken is the size of the largest
+// token, which with bufio.Scanner defaults to 64KB if it is 0 or less.  A
+// split of ScanNUL reads the output of commands such as find -print0.  The
+// token passed to fn may be overwritten once fn returns.  Standard error is
+// discarded.
+//
+// Scan returns once the Executable has finished, with its error if any.  If
+// a token is larger than maxToken, the Executable is killed, and the error is
+// bufio.ErrTooLong.
+func (c Executable) Scan(stdin string, split bufio.SplitFunc, maxToken int, fn func(token []byte)) error {
+	r, err := c.reader(strings.NewReader(stdin))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	sc := bufio.NewScanner(r)
+	if split != nil {
+		sc.Split(split)
+	}
+	if maxToken > 0 {
+		sc.Buffer(make([]byte, 0, min(maxToken, 4096)), maxToken)
+	}
+	for sc.Scan() {
+		fn(sc.Bytes())
+	}
+	return sc.Err()
+}
+
+// ScanNUL is a bufio.SplitFunc that splits at NUL bytes, which unlike
+// newlines can't appear in file names, as written by find -print0.  The NULs
+// are not part of the tokens.  Data after the last NUL is a token of its own.
+func ScanNUL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Lines starts the Executable with the given string as standard input, and
+// sends each line of its standard output, minus its newline, on the first
+// channel as soon as it is written.  Once the output ends, the first channel
+// is closed, then the Executable's error, if any, is sent on the second
+// channel, and it is closed too.  Standard error is discarded.
+//
+// Lines are never dropped: while a line waits to be received, the Executable
+// is held up.  So the lines channel must be read until it is closed, or the
+// Executable will never finish.
+func (c Executable) Lines(stdin string) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		err := c.RunFunc(stdin, func(line string) { lines <- line })
+		close(lines)
+		if err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+	return lines, errc
+}
+
+// Result is the outcome of running an Executable: its combined stdout and
+// stderr, as returned by Run, and its error if any.
+type Result struct {
+	Output string
+	Err    error
+}
+
+// Writer starts a Pipe of cmds, and returns a writer that feeds the stdin of
+// its first command, so that input can be generated with Go code, such as
+// fmt.Fprintf, and streamed through the pipe.  Closing the writer ends the
+// input and waits for the pipe to finish, and the Result is then sent on the
+// channel, which is closed after.  If the pipe finishes before all of its
+// input is written, writes fail with io.ErrClosedPipe from then on.
+func Writer(cmds ...Executable) (io.WriteCloser, <-chan Result) {
+	pr, pw := io.Pipe()
+	results := make(chan Result, 1)
+	done := make(chan struct{})
+	go func() {
+		out, err := Pipe(cmds...).run(context.Background(), pr)
+		pr.Close()
+		results <- Result{Output: out, Err: err}
+		close(results)
+		close(done)
+	}()
+	return &writer{PipeWriter: pw, done: done}, results
+}
+
+// writer is the io.WriteCloser returned by Writer.
+type writer struct {
+	*io.PipeWriter
+	done chan struct{}
+}
+
+func (w *writer) Close() error {
+	w.PipeWriter.Close()
+	<-w.done
+	return nil
+}
@@ -0,0 +1,142 @@
+package sh_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleExecutable_RunFunc() {
+	grep := sh.Cmd("grep")
+
+	n := 0
+	err := grep("Rebel").RunFunc(SWCrawl, func(line string) {
+		n++
+		fmt.Printf("%d: %s\n", n, line)
+	})
+	fmt.Println(err)
+	// output:
+	// 1: It is a period of civil war. Rebel
+	// 2: During the battle, Rebel spies managed
+	// <nil>
+}
+
+func ExampleExecutable_Lines() {
+	grep := sh.Cmd("grep")
+
+	lines, errc := grep("Empire").Lines(SWCrawl)
+	for line := range lines {
+		fmt.Println(">", line)
+	}
+	fmt.Println(<-errc)
+	// output:
+	// > against the evil Galactic Empire.
+	// > to steal secret plans to the Empire's
+	// > Pursued by the Empire's sinister agents,
+	// <nil>
+}
+
+func TestLinesError(t *testing.T) {
+	lines, errc := sh.Shell("echo one; exit 2").Lines("")
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	if want := []string{"one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+	var exitErr *sh.ExitError
+	if err := <-errc; !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Errorf("got error %v, want exit status 2", err)
+	}
+	if _, ok := <-errc; ok {
+		t.Error("error channel not closed after the error")
+	}
+}
+
+func TestRunFuncPartialLine(t *testing.T) {
+	var lines []string
+	err := sh.Shell("printf 'one\\ntwo'; exit 3").RunFunc("", func(line string) {
+		lines = append(lines, line)
+	})
+	if want := []string{"one", "two"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %q, want %q", lines, want)
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+}
+
+func ExampleExecutable_Scan() {
+	printf := sh.Cmd("printf")
+
+	err := printf(`one\000two\nlines\000`).Scan("", sh.ScanNUL, 0, func(name []byte) {
+		fmt.Printf("%q\n", name)
+	})
+	fmt.Println(err)
+	// output:
+	// "one"
+	// "two\nlines"
+	// <nil>
+}
+
+func TestScanTooLong(t *testing.T) {
+	var tokens []string
+	err := sh.Cat().Scan("short\n"+strings.Repeat("x", 100)+"\n", nil, 10, func(tok []byte) {
+		tokens = append(tokens, string(tok))
+	})
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got error %v, want %v", err, bufio.ErrTooLong)
+	}
+	if !reflect.DeepEqual(tokens, []string{"short"}) {
+		t.Errorf("got tokens %q, want just the short one", tokens)
+	}
+
+	// A big enough buffer reads long lines.
+	n := 0
+	err = sh.Cat().Scan(strings.Repeat("x", 100000)+"\n", bufio.ScanLines, 200000, func(tok []byte) {
+		n = len(tok)
+	})
+	if err != nil || n != 100000 {
+		t.Errorf("got a %d byte token and %v, want 100000 and nil", n, err)
+	}
+}
+
+func ExampleWriter() {
+	w, result := sh.Writer(sh.Cmd("sort")(), sh.Head(2))
+	for _, name := range []string{"mallory", "alice", "trent", "bob"} {
+		fmt.Fprintln(w, name)
+	}
+	w.Close()
+	r := <-result
+	fmt.Print(r.Output)
+	fmt.Println(r.Err)
+	// output:
+	// alice
+	// bob
+	// <nil>
+}
+
+func TestWriterPipeFinishesEarly(t *testing.T) {
+	w, result := sh.Writer(sh.Head(1))
+	_, err := io.WriteString(w, strings.Repeat("line\n", 100000))
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got write error %v, want io.ErrClosedPipe", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("got close error %v", err)
+	}
+	if r := <-result; r.Err != nil || r.Output != "line\n" {
+		t.Errorf("got %q, %v, want %q, nil", r.Output, r.Err, "line\n")
+	}
+	if _, ok := <-result; ok {
+		t.Error("result channel not closed")
+	}
+}
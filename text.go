@@ -0,0 +1,596 @@
+package sh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"labix.org/v2/pipe"
+)
+
+// Sort returns an Executable that reads all the lines of its stdin and writes
+// them to its stdout in sorted order, like sort.  By default lines are sorted
+// by comparing their bytes, so the order is the same whatever the locale.
+// Lines that compare equal keep the order they came in.  Every line is
+// written with a newline, even a final line that had none.  All of stdin is
+// held in memory while it is sorted.
+func Sort(opts ...SortOption) Executable {
+	var o sortOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		var lines []string
+		err := forEachLine(r, func(line string, _ bool) error {
+			lines = append(lines, line)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		less := func(a, b string) bool { return a < b }
+		if o.numeric {
+			less = func(a, b string) bool {
+				na, nb := leadingNumber(a), leadingNumber(b)
+				if na != nb {
+					return na < nb
+				}
+				return a < b
+			}
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			if o.reverse {
+				return less(lines[j], lines[i])
+			}
+			return less(lines[i], lines[j])
+		})
+		for _, line := range lines {
+			if err := writeLine(w, line, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Reverse returns an Executable that reads all the lines of its stdin and
+// writes them to its stdout last first, like tac.  As with Uniq, the final
+// line is written with a newline only if the input's final line had one, so
+// reversing twice gives back the input.  All of stdin is held in memory.
+func Reverse() Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		var lines []string
+		eol := true
+		err := forEachLine(r, func(line string, e bool) error {
+			lines = append(lines, line)
+			eol = e
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if err := writeLine(w, lines[i], eol || i > 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Number returns an Executable that copies its stdin to its stdout with each
+// line led by its number, counting from 1, like cat -n: by default, the
+// number is right-aligned in 6 columns and followed by a tab.  Every line is
+// numbered, including blank ones.  The final line is written with a newline
+// only if the input's final line had one.
+func Number(opts ...NumberOption) Executable {
+	o := numberOptions{width: 6, sep: "\t"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	format := "%*d"
+	switch {
+	case o.left:
+		format = "%-*d"
+	case o.zeros:
+		format = "%0*d"
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		n := 0
+		return forEachLine(r, func(line string, eol bool) error {
+			n++
+			return writeLine(w, fmt.Sprintf(format, o.width, n)+o.sep+line, eol)
+		})
+	})
+}
+
+// NumberOption configures Number.
+type NumberOption func(*numberOptions)
+
+type numberOptions struct {
+	width int
+	sep   string
+	left  bool
+	zeros bool
+}
+
+// NumberWidth sets the number of columns Number pads line numbers to, which is
+// 6 by default.  Numbers that are wider are written whole.  A width of 0
+// leaves numbers unpadded.
+func NumberWidth(width int) NumberOption {
+	return func(o *numberOptions) {
+		o.width = width
+	}
+}
+
+// NumberSeparator sets what Number writes between a line number and its line,
+// which is a tab by default.
+func NumberSeparator(sep string) NumberOption {
+	return func(o *numberOptions) {
+		o.sep = sep
+	}
+}
+
+// NumberLeft makes Number pad line numbers on the right, so that they are
+// left-aligned, like nl -n ln.
+func NumberLeft() NumberOption {
+	return func(o *numberOptions) {
+		o.left = true
+	}
+}
+
+// NumberZeros makes Number pad line numbers with leading zeros rather than
+// spaces, like nl -n rz.  It has no effect with NumberLeft.
+func NumberZeros() NumberOption {
+	return func(o *numberOptions) {
+		o.zeros = true
+	}
+}
+
+// SortOption configures Sort.
+type SortOption func(*sortOptions)
+
+type sortOptions struct {
+	numeric bool
+	reverse bool
+}
+
+// SortNumeric makes Sort compare lines by the number they start with, like
+// sort -n.  Leading blanks are skipped, and a line that doesn't start with a
+// number counts as 0.  Lines with the same number are sorted by their bytes.
+func SortNumeric() SortOption {
+	return func(o *sortOptions) {
+		o.numeric = true
+	}
+}
+
+// SortReverse makes Sort write lines in reverse order, like sort -r.
+func SortReverse() SortOption {
+	return func(o *sortOptions) {
+		o.reverse = true
+	}
+}
+
+// leadingNumber returns the number at the start of s after any blanks, such
+// as -1.5 in "  -1.5 apples", or 0 if there is none.
+func leadingNumber(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	end := 0
+	if end < len(s) && s[end] == '-' {
+		end++
+	}
+	dot := false
+	for ; end < len(s); end++ {
+		if s[end] == '.' && !dot {
+			dot = true
+			continue
+		}
+		if s[end] < '0' || s[end] > '9' {
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Uniq returns an Executable that copies its stdin to its stdout, dropping
+// lines that are the same as the line before them, like uniq.  Input is
+// usually sorted first, so that all copies of a line are next to each other.
+// The final line is written with a newline only if the input's final line
+// had one.
+func Uniq(opts ...UniqOption) Executable {
+	var o uniqOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		write := func(g uniqGroup, eol bool) error {
+			line := g.line
+			if o.count {
+				line = fmt.Sprintf("%7d %s", g.n, g.line)
+			}
+			return writeLine(w, line, eol)
+		}
+		// groups are the lines not yet written, in the order they first
+		// appeared.  Without UniqAll, there is at most one.
+		var groups []uniqGroup
+		index := make(map[string]int)
+		eol := true
+		err := forEachLine(r, func(line string, e bool) error {
+			eol = e
+			if o.all {
+				if i, ok := index[line]; ok {
+					groups[i].n++
+					return nil
+				}
+				index[line] = len(groups)
+				groups = append(groups, uniqGroup{line: line, n: 1})
+				return nil
+			}
+			if len(groups) == 1 {
+				if groups[0].line == line {
+					groups[0].n++
+					return nil
+				}
+				if err := write(groups[0], true); err != nil {
+					return err
+				}
+				groups = groups[:0]
+			}
+			groups = append(groups, uniqGroup{line: line, n: 1})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, g := range groups {
+			if err := write(g, eol || i < len(groups)-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// uniqGroup is a line written by Uniq, and the number of times it was read.
+type uniqGroup struct {
+	line string
+	n    int
+}
+
+// UniqOption configures Uniq.
+type UniqOption func(*uniqOptions)
+
+type uniqOptions struct {
+	count bool
+	all   bool
+}
+
+// UniqCount makes Uniq start each line with the number of times it was read,
+// padded as uniq -c pads it.
+func UniqCount() UniqOption {
+	return func(o *uniqOptions) {
+		o.count = true
+	}
+}
+
+// UniqAll makes Uniq drop every line that is the same as any line before it,
+// not just the one right before it, so that its input need not be sorted.
+// Lines are written in the order they first appear, once all of stdin has
+// been read, and every distinct line is held in memory until then.
+func UniqAll() UniqOption {
+	return func(o *uniqOptions) {
+		o.all = true
+	}
+}
+
+// CountLines returns an Executable that writes the number of lines in its
+// stdin to its stdout, followed by a newline, like wc -l.  As with wc, it
+// counts newlines, so a final line with no newline is not counted.
+func CountLines() Executable {
+	return count(func(br *bufio.Reader) (int64, error) {
+		var n int64
+		for {
+			_, err := br.ReadSlice('\n')
+			if err == nil {
+				n++
+				continue
+			}
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+	})
+}
+
+// CountWords returns an Executable that writes the number of words in its
+// stdin to its stdout, followed by a newline, like wc -w.  A word is a run of
+// characters other than white space, as defined by unicode.IsSpace.
+func CountWords() Executable {
+	return count(func(br *bufio.Reader) (int64, error) {
+		var n int64
+		inWord := false
+		for {
+			r, _, err := br.ReadRune()
+			if err == io.EOF {
+				return n, nil
+			}
+			if err != nil {
+				return n, err
+			}
+			if unicode.IsSpace(r) {
+				inWord = false
+			} else if !inWord {
+				inWord = true
+				n++
+			}
+		}
+	})
+}
+
+// CountBytes returns an Executable that writes the number of bytes in its
+// stdin to its stdout, followed by a newline, like wc -c.
+func CountBytes() Executable {
+	return count(func(br *bufio.Reader) (int64, error) {
+		return io.Copy(io.Discard, br)
+	})
+}
+
+// count returns an Executable that writes the number fn counts in its stdin.
+func count(fn func(br *bufio.Reader) (int64, error)) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		n, err := fn(bufio.NewReader(r))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, n)
+		return err
+	})
+}
+
+// Replace returns an Executable that copies its stdin to its stdout with every
+// match of the regular expression pattern replaced by replacement, like sed
+// s/pattern/replacement/g.  Patterns use the syntax of the regexp package,
+// and replacement may refer to submatches as $1 or ${name}, as with
+// regexp.Regexp.ReplaceAllString; a literal $ is written $$.  By default each
+// line is matched on its own, minus its newline, so matches never span lines
+// and ^ and $ match at the start and end of each line; see ReplaceWhole.
+func Replace(pattern, replacement string, opts ...ReplaceOption) Executable {
+	var o replaceOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Executable{func(*pipe.State) error { return err }}
+	}
+	if o.whole {
+		return Func(func(r io.Reader, w io.Writer) error {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(re.ReplaceAll(b, []byte(replacement)))
+			return err
+		})
+	}
+	return MapLines(func(line string) string {
+		return re.ReplaceAllString(line, replacement)
+	})
+}
+
+// ReplaceOption configures Replace.
+type ReplaceOption func(*replaceOptions)
+
+type replaceOptions struct {
+	whole bool
+}
+
+// ReplaceWhole makes Replace match pattern against all of its stdin at once,
+// so that matches may span lines, as a pattern with \n in it needs.  As in
+// the regexp package, ^ and $ then match only at the start and end of the
+// input, unless the pattern starts with (?m).  All of stdin is read into
+// memory before anything is written.
+func ReplaceWhole() ReplaceOption {
+	return func(o *replaceOptions) {
+		o.whole = true
+	}
+}
+
+// Cut returns an Executable that writes the given fields of each line of its
+// stdin to its stdout, like cut -d delim -f.  Fields are split at delim and
+// counted from 1.  As with cut, the fields are written in the order they are
+// in the line, each only once whatever order they are given in, joined by
+// delim; fields past the end of the line are skipped, and a line with no
+// delim in it is written whole.  An empty delim splits at runs of white
+// space instead, ignoring any at the start and end of the line, as awk does,
+// and joins the fields with single spaces.
+func Cut(delim string, fields ...int) Executable {
+	want := make(map[int]bool, len(fields))
+	for _, f := range fields {
+		want[f] = true
+	}
+	return MapLines(func(line string) string {
+		var parts []string
+		sep := delim
+		if delim == "" {
+			parts, sep = strings.Fields(line), " "
+		} else if strings.Contains(line, delim) {
+			parts = strings.Split(line, delim)
+		} else {
+			return line
+		}
+		kept := parts[:0]
+		for i, p := range parts {
+			if want[i+1] {
+				kept = append(kept, p)
+			}
+		}
+		return strings.Join(kept, sep)
+	})
+}
+
+// ToUpper returns an Executable that copies its stdin to its stdout with every
+// letter changed to upper case, like tr '[:lower:]' '[:upper:]', but by the
+// rules of Unicode rather than those of a locale, so letters written with
+// more than one byte, such as é, are changed too.  Bytes that are not valid
+// UTF-8 are copied as they are.
+func ToUpper() Executable {
+	return mapRunes(unicode.ToUpper)
+}
+
+// ToLower works like ToUpper, but changes letters to lower case.
+func ToLower() Executable {
+	return mapRunes(unicode.ToLower)
+}
+
+// Tr returns an Executable that copies its stdin to its stdout with each
+// character in from replaced by the character at the same position in to,
+// like tr.  A range such as a-z stands for all the characters from a to z,
+// and a - at the start or end of from or to stands for itself.  If to is
+// shorter than from, its last character is repeated to make up the length.
+// If to is empty, the characters in from are deleted instead, like tr -d.
+// Characters are Unicode code points, not bytes, so multibyte characters are
+// replaced whole.
+func Tr(from, to string) Executable {
+	f, t := trSet(from), trSet(to)
+	m := make(map[rune]rune, len(f))
+	for i, r := range f {
+		switch {
+		case len(t) == 0:
+			m[r] = -1
+		case i < len(t):
+			m[r] = t[i]
+		default:
+			m[r] = t[len(t)-1]
+		}
+	}
+	return mapRunes(func(r rune) rune {
+		if to, ok := m[r]; ok {
+			return to
+		}
+		return r
+	})
+}
+
+// trSet returns the characters of set, with ranges such as a-z expanded.
+func trSet(set string) []rune {
+	in := []rune(set)
+	var out []rune
+	for i := 0; i < len(in); i++ {
+		if i+2 < len(in) && in[i+1] == '-' && in[i] <= in[i+2] {
+			for r := in[i]; r <= in[i+2]; r++ {
+				out = append(out, r)
+			}
+			i += 2
+			continue
+		}
+		out = append(out, in[i])
+	}
+	return out
+}
+
+// mapRunes returns an Executable that copies its stdin to its stdout with each
+// character changed by fn, or dropped if fn returns a negative value, as with
+// strings.Map.  Bytes that are not valid UTF-8 are copied as they are.
+func mapRunes(fn func(r rune) rune) Executable {
+	return Func(func(r io.Reader, w io.Writer) error {
+		br := bufio.NewReader(r)
+		bw := bufio.NewWriter(w)
+		for {
+			c, size, err := br.ReadRune()
+			if err == io.EOF {
+				return bw.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			if c == utf8.RuneError && size == 1 {
+				br.UnreadRune()
+				b, _ := br.ReadByte()
+				err = bw.WriteByte(b)
+			} else if c = fn(c); c >= 0 {
+				_, err = bw.WriteRune(c)
+			}
+			if err != nil {
+				return err
+			}
+			// Flush once there is no more input waiting, so that output
+			// streams.
+			if br.Buffered() == 0 {
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// Strings returns an Executable that writes each run of at least minLen
+// printable characters in its stdin to its stdout, on a line of its own, like
+// strings -n, for finding the text in binary files.  As for GNU strings,
+// printable characters are the printable ASCII characters and tab; any other
+// byte, such as NUL or a byte of a UTF-8 character that is not ASCII, ends a
+// run.  A minLen less than 1 is taken as 1.  Runs are streamed, so a long one
+// is written as it is read.
+func Strings(minLen int) Executable {
+	if minLen < 1 {
+		minLen = 1
+	}
+	return Func(func(r io.Reader, w io.Writer) error {
+		br := bufio.NewReader(r)
+		bw := bufio.NewWriter(w)
+		// run holds the start of the current run until it is long enough
+		// to be written; after that, the rest of the run is written as it
+		// is read.
+		run := make([]byte, 0, minLen)
+		writing := false
+		for {
+			c, err := br.ReadByte()
+			if err == io.EOF {
+				if writing {
+					bw.WriteByte('\n')
+				}
+				return bw.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			switch {
+			case c == '\t' || c >= ' ' && c <= '~':
+				if writing {
+					bw.WriteByte(c)
+				} else if run = append(run, c); len(run) == minLen {
+					bw.Write(run)
+					writing = true
+				}
+			default:
+				run = run[:0]
+				if writing {
+					bw.WriteByte('\n')
+					writing = false
+				}
+			}
+			// Flush once there is no more input waiting, so that output
+			// streams.
+			if br.Buffered() == 0 {
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
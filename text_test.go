@@ -0,0 +1,309 @@
+package sh_test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleSort() {
+	fmt.Print(sh.PipeWith("10 pears\n9 apples\n-1 plums\n10 figs\n", sh.Sort(sh.SortNumeric(), sh.SortReverse())))
+	// output:
+	// 10 pears
+	// 10 figs
+	// 9 apples
+	// -1 plums
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []sh.SortOption
+		in   string
+		want string
+	}{
+		{"bytes", nil, "b\nB\na\n", "B\na\nb\n"},
+		{"no final newline", nil, "b\na", "a\nb\n"},
+		{"empty", nil, "", ""},
+		{"numeric", []sh.SortOption{sh.SortNumeric()}, "10\n9\n x\n1.5\n-2\n", "-2\n x\n1.5\n9\n10\n"},
+		{"reverse", []sh.SortOption{sh.SortReverse()}, "a\nc\nb\n", "c\nb\na\n"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, sh.Sort(tt.opts...)).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+}
+
+func TestSortMany(t *testing.T) {
+	const n = 300000
+	var b strings.Builder
+	for i := n; i > 0; i-- {
+		b.WriteString(strconv.Itoa(i) + "\n")
+	}
+	out, err := sh.PipeWith(b.String(), sh.Sort(sh.SortNumeric()), sh.Head(2)).Run()
+	if err != nil || out != "1\n2\n" {
+		t.Errorf("got %q, %v, want %q, nil", out, err, "1\n2\n")
+	}
+}
+
+func ExampleReverse() {
+	fmt.Print(sh.PipeWith("first\nsecond\nthird\n", sh.Reverse()))
+	// output:
+	// third
+	// second
+	// first
+}
+
+func TestReverse(t *testing.T) {
+	for _, in := range []string{"a\nb\n", "a\nb", "a", "", "\n\n"} {
+		once, err := sh.PipeWith(in, sh.Reverse()).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		twice, err := sh.PipeWith(in, sh.Reverse(), sh.Reverse()).Run()
+		if err != nil || twice != in {
+			t.Errorf("reversing %q twice got %q (once %q), %v", in, twice, once, err)
+		}
+	}
+	if out := sh.PipeWith("a\nb", sh.Reverse()).String(); out != "b\na" {
+		t.Errorf("got %q, want %q", out, "b\na")
+	}
+}
+
+func ExampleNumber() {
+	log := "starting\nloading config\nerror: no such file\nretrying\nerror: gave up\n"
+
+	// the errors in log, with the numbers of their lines
+	fmt.Print(sh.PipeWith(log, sh.Number(sh.NumberZeros(), sh.NumberWidth(3), sh.NumberSeparator(": ")), sh.Grep("error")))
+	// output:
+	// 003: error: no such file
+	// 005: error: gave up
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts []sh.NumberOption
+		want string
+	}{
+		{"a\nb\n", nil, "     1\ta\n     2\tb\n"},
+		{"a\n\nb", nil, "     1\ta\n     2\t\n     3\tb"},
+		{"", nil, ""},
+		{"a\n", []sh.NumberOption{sh.NumberWidth(0), sh.NumberSeparator(" ")}, "1 a\n"},
+		{"a\nb\n", []sh.NumberOption{sh.NumberWidth(3), sh.NumberLeft()}, "1  \ta\n2  \tb\n"},
+		{"a\n", []sh.NumberOption{sh.NumberWidth(3), sh.NumberZeros()}, "001\ta\n"},
+		{"a\n", []sh.NumberOption{sh.NumberWidth(3), sh.NumberZeros(), sh.NumberLeft()}, "1  \ta\n"},
+		{strings.Repeat("x\n", 10), []sh.NumberOption{sh.NumberWidth(1), sh.NumberSeparator(":")}, "1:x\n2:x\n3:x\n4:x\n5:x\n6:x\n7:x\n8:x\n9:x\n10:x\n"},
+	}
+	for _, tt := range tests {
+		got, err := sh.PipeWith(tt.in, sh.Number(tt.opts...)).Run()
+		if err != nil || got != tt.want {
+			t.Errorf("numbering %q: got %q, %v, want %q, nil", tt.in, got, err, tt.want)
+		}
+	}
+
+	// Each run counts from 1 again.
+	number := sh.Number(sh.NumberWidth(0))
+	for i := 0; i < 2; i++ {
+		if got := sh.PipeWith("a\n", number).String(); got != "1\ta\n" {
+			t.Errorf("run %d: got %q, want %q", i+1, got, "1\ta\n")
+		}
+	}
+}
+
+func ExampleUniq() {
+	words := "the\ncat\nsat\non\nthe\nmat\nthe\nend\n"
+
+	// sort | uniq -c | sort -rn | head -2
+	fmt.Print(sh.PipeWith(words, sh.Sort(), sh.Uniq(sh.UniqCount()), sh.Sort(sh.SortNumeric(), sh.SortReverse()), sh.Head(2)))
+	// output:
+	//       3 the
+	//       1 sat
+}
+
+func TestUniq(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []sh.UniqOption
+		in   string
+		want string
+	}{
+		{"adjacent", nil, "a\na\nb\na\n", "a\nb\na\n"},
+		{"no final newline", nil, "a\nb\nb", "a\nb"},
+		{"empty", nil, "", ""},
+		{"count", []sh.UniqOption{sh.UniqCount()}, "a\na\nb\n", "      2 a\n      1 b\n"},
+		{"all", []sh.UniqOption{sh.UniqAll()}, "b\na\nb\na\nc", "b\na\nc"},
+		{"all count", []sh.UniqOption{sh.UniqAll(), sh.UniqCount()}, "b\na\nb\n", "      2 b\n      1 a\n"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, sh.Uniq(tt.opts...)).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+}
+
+func ExampleCountWords() {
+	fmt.Print(sh.Pipe(sh.Echo("Hi there!"), sh.CountWords()))
+	// output:
+	// 2
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name string
+		c    sh.Executable
+		in   string
+		want string
+	}{
+		{"lines", sh.CountLines(), "a\nb\n", "2\n"},
+		{"lines no final newline", sh.CountLines(), "a\nb", "1\n"},
+		{"lines empty", sh.CountLines(), "", "0\n"},
+		{"long line", sh.CountLines(), strings.Repeat("x", 10000) + "\n", "1\n"},
+		{"words", sh.CountWords(), "  one\ttwo\n\nthree", "3\n"},
+		{"words unicode space", sh.CountWords(), "a\u00a0b", "2\n"},
+		{"words empty", sh.CountWords(), "", "0\n"},
+		{"bytes", sh.CountBytes(), "h\u00e9\n", "4\n"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, tt.c).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+}
+
+func ExampleReplace() {
+	fmt.Print(sh.PipeWith("name=alice\nname=bob\n", sh.Replace(`^name=(\w+)`, "user: $1")))
+	// output:
+	// user: alice
+	// user: bob
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name         string
+		pattern, rep string
+		opts         []sh.ReplaceOption
+		in, want     string
+	}{
+		{"every match", "o", "0", nil, "foo\nboo", "f00\nb00"},
+		{"per line anchors", "^", "> ", nil, "a\nb\n", "> a\n> b\n"},
+		{"no span", "a\nb", "X", nil, "a\nb\n", "a\nb\n"},
+		{"whole span", "a\nb", "X", []sh.ReplaceOption{sh.ReplaceWhole()}, "a\nb\n", "X\n"},
+		{"named group", `(?P<k>\w+)=(?P<v>\w+)`, "${v}=${k}", nil, "a=1\n", "1=a\n"},
+		{"literal dollar", "cost", "$$5", nil, "cost\n", "$5\n"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, sh.Replace(tt.pattern, tt.rep, tt.opts...)).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+	if _, err := sh.Replace("(", "").Run(); err == nil {
+		t.Error("bad pattern: got no error")
+	}
+}
+
+func ExampleCut() {
+	ps := "  PID TTY      CMD\n 4242 pts/0    bash\n"
+	fmt.Print(sh.PipeWith(ps, sh.Cut("", 3, 1)))
+	// output:
+	// PID CMD
+	// 4242 bash
+}
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		name   string
+		delim  string
+		fields []int
+		in     string
+		want   string
+	}{
+		{"fields", ":", []int{1, 3}, "a:b:c:d\n", "a:c\n"},
+		{"in line order", ",", []int{3, 1, 1}, "a,b,c\n", "a,c\n"},
+		{"out of range", ":", []int{2, 9}, "a:b\n", "b\n"},
+		{"no delim", ":", []int{2}, "abc\n", "abc\n"},
+		{"empty fields", ",", []int{2, 3}, "a,,c", ",c"},
+		{"long delim", "::", []int{2}, "a::b::c\n", "b\n"},
+		{"white space", "", []int{2}, " a \t b  c\nd\n", "b\n\n"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, sh.Cut(tt.delim, tt.fields...)).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+}
+
+func ExampleToUpper() {
+	fmt.Print(sh.PipeWith("déjà vu\n", sh.ToUpper()))
+	// output:
+	// DÉJÀ VU
+}
+
+func ExampleTr() {
+	fmt.Print(sh.PipeWith("hello, world\n", sh.Tr("a-y", "b-z")))
+	fmt.Print(sh.PipeWith("hello, world\n", sh.Tr("lo", "")))
+	// output:
+	// ifmmp, xpsme
+	// he, wrd
+}
+
+func TestTr(t *testing.T) {
+	tests := []struct {
+		name, from, to, in, want string
+	}{
+		{"short to", "abc", "x", "aabbcc d", "xxxxxx d"},
+		{"newlines", "\n", " ", "a\nb\n", "a b "},
+		{"dash", "a-", "x_", "a-b", "x_b"},
+		{"multibyte", "é", "e", "café", "cafe"},
+		{"invalid utf8", "a", "b", "a\xffa", "b\xffb"},
+	}
+	for _, tt := range tests {
+		out, err := sh.PipeWith(tt.in, sh.Tr(tt.from, tt.to)).Run()
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, nil", tt.name, out, err, tt.want)
+		}
+	}
+	if out := sh.PipeWith("ÀB\xff", sh.ToLower()).String(); out != "àb\xff" {
+		t.Errorf("ToLower got %q, want %q", out, "àb\xff")
+	}
+}
+
+func ExampleStrings() {
+	binary := []byte("\x7fELF\x02\x01\x00\x00GCC: (GNU) 13.2\x00\x01\x02ok\x00/lib/ld-linux.so.2\x00")
+	fmt.Print(sh.Pipe(sh.Bytes(binary), sh.Strings(4)))
+	// output:
+	// GCC: (GNU) 13.2
+	// /lib/ld-linux.so.2
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		in     string
+		minLen int
+		want   string
+	}{
+		{"", 4, ""},
+		{"abc", 4, ""},
+		{"abcd", 4, "abcd\n"},
+		{"ab\x00cd\x00efgh", 2, "ab\ncd\nefgh\n"},
+		{"a\tb c\nd", 3, "a\tb c\n"},
+		{"\xffabc\xe9def", 3, "abc\ndef\n"},
+		{"x\x00y", 0, "x\ny\n"},
+		{strings.Repeat("z", 10000) + "\x00", 4, strings.Repeat("z", 10000) + "\n"},
+	}
+	for _, tt := range tests {
+		got, err := sh.PipeWith(tt.in, sh.Strings(tt.minLen)).Run()
+		if err != nil || got != tt.want {
+			t.Errorf("Strings(%d) of %q: got %q, %v, want %q, nil", tt.minLen, tt.in, got, err, tt.want)
+		}
+	}
+}
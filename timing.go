@@ -0,0 +1,74 @@
+package sh
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing says when an Executable run with RunTimed started and finished, and
+// when each of its commands did.
+type Timing struct {
+	// Start and End are when the run started and finished.
+	Start, End time.Time
+	// Commands holds the timing of each command that was run, in the order
+	// they started.  Stages written in Go, such as Grep, are not included.
+	Commands []CommandTiming
+}
+
+// Duration returns how long the run took.
+func (t Timing) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// CommandTiming says when a command started and finished.
+type CommandTiming struct {
+	// Name and Args are the name and arguments of the command.
+	Name string
+	Args []string
+	// Stage is the position of the command in its Pipe, counting from 1, or
+	// 0 if it did not run in a Pipe, as for ExitError.
+	Stage int
+	// Start and End are when the command started and exited.
+	Start, End time.Time
+}
+
+// Duration returns how long the command ran.
+func (t CommandTiming) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// RunTimed works like RunWith, but also returns when the Executable and each of
+// its commands started and finished, to find the slow stage of a Pipe.
+func (c Executable) RunTimed(stdin string) (string, Timing, error) {
+	ts := &timings{}
+	timed := Executable{withSettings(c.Pipe, func(st *settings) { st.timings = ts })}
+	t := Timing{Start: time.Now()}
+	out, err := timed.run(context.Background(), strings.NewReader(stdin))
+	t.End = time.Now()
+	t.Commands = ts.sorted()
+	return out, t, err
+}
+
+// timings collects the timings of commands as they finish.
+type timings struct {
+	mu   sync.Mutex
+	cmds []CommandTiming
+}
+
+func (ts *timings) add(t CommandTiming) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.cmds = append(ts.cmds, t)
+}
+
+// sorted returns the timings collected, in the order the commands started.
+func (ts *timings) sorted() []CommandTiming {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	cmds := append([]CommandTiming(nil), ts.cmds...)
+	sort.SliceStable(cmds, func(i, j int) bool { return cmds[i].Start.Before(cmds[j].Start) })
+	return cmds
+}
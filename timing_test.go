@@ -0,0 +1,43 @@
+package sh_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleExecutable_RunTimed() {
+	sleep := sh.Cmd("sleep")
+	echo := sh.Cmd("echo")
+
+	_, timing, err := sh.Pipe(echo("hi"), sleep("0.2")).RunTimed("")
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range timing.Commands {
+		fmt.Println(c.Stage, c.Name, c.Duration() >= 200*time.Millisecond)
+	}
+	// Unordered output:
+	// 1 echo false
+	// 2 sleep true
+}
+
+func TestRunTimed(t *testing.T) {
+	before := time.Now()
+	out, timing, err := sh.Cmd("echo")("hi").RunTimed("")
+	if err != nil || out != "hi\n" {
+		t.Fatalf("got %q, %v, want %q, nil", out, err, "hi\n")
+	}
+	if timing.Start.Before(before) || timing.End.Before(timing.Start) || timing.Duration() <= 0 {
+		t.Errorf("bad run timing %+v", timing)
+	}
+	if len(timing.Commands) != 1 {
+		t.Fatalf("got %d command timings, want 1", len(timing.Commands))
+	}
+	c := timing.Commands[0]
+	if c.Name != "echo" || c.Stage != 0 || c.Start.Before(timing.Start) || c.End.After(timing.End) {
+		t.Errorf("bad command timing %+v", c)
+	}
+}
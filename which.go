@@ -0,0 +1,86 @@
+package sh
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Which returns the path of the executable that running the named command
+// would run, by searching the directories in the PATH environment variable,
+// like exec.LookPath.  If the command can't be found, the error says so, for
+// failing fast before a long pipeline is run.
+func Which(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+// Check checks that every command c would run can be found, without running
+// anything.  Each command is looked up the way it would be when run, so a
+// PATH set with WithEnv or WithExtraEnv is searched instead of the PATH of
+// this process.  The error joins an error for every command that can't be
+// found, each of which is ErrNotFound if the command doesn't exist.
+func (c Executable) Check() error {
+	cmds, err := c.plan()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, cmd := range cmds {
+		path, ok := pathOf(cmd.env)
+		if !ok {
+			path = os.Getenv("PATH")
+		}
+		if _, err := lookPath(cmd.name, path, cmd.dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pathOf returns the value of PATH in env, and whether it is set.  A nil env
+// means the environment of this process.
+func pathOf(env []string) (string, bool) {
+	if env == nil {
+		return os.LookupEnv("PATH")
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if v, ok := strings.CutPrefix(env[i], "PATH="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// lookPath works like exec.LookPath, but searches the directories in path
+// instead of the PATH of this process.  A name with a path separator in it is
+// not searched for, and if it is relative, it is relative to dir.
+func lookPath(name, path, dir string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		file := name
+		if dir != "" && !filepath.IsAbs(name) {
+			file = filepath.Join(dir, name)
+		}
+		if _, err := exec.LookPath(file); err != nil {
+			err = errors.Unwrap(err)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = &notFoundError{err}
+			}
+			return "", &exec.Error{Name: name, Err: err}
+		}
+		return name, nil
+	}
+	for _, d := range filepath.SplitList(path) {
+		if d == "" || !filepath.IsAbs(d) {
+			// like exec.LookPath, don't run things from the current
+			// directory just because it happens to be in PATH
+			continue
+		}
+		if file, err := exec.LookPath(filepath.Join(d, name)); err == nil {
+			return file, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
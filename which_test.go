@@ -0,0 +1,67 @@
+package sh_test
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleWhich() {
+	if _, err := sh.Which("thiswontwork"); err != nil {
+		fmt.Println(err)
+	}
+	path, err := sh.Which("sh")
+	fmt.Println(filepath.IsAbs(path), err)
+	// output:
+	// exec: "thiswontwork": executable file not found in $PATH
+	// true <nil>
+}
+
+func TestCheck(t *testing.T) {
+	echo := sh.Cmd("echo")
+	grep := sh.Cmd("grep")
+	nope := sh.Cmd("thiswontwork")
+
+	if err := sh.Pipe(echo("hi"), grep("h")).Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := sh.Pipe(echo("hi"), nope(), grep("h"), sh.And(nope())).Check()
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected exec.ErrNotFound, got %v", err)
+	}
+	expected := "exec: \"thiswontwork\": executable file not found in $PATH\n" +
+		"exec: \"thiswontwork\": executable file not found in $PATH"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestCheckUsesEnvPath(t *testing.T) {
+	echo := sh.Cmd("echo")
+
+	if err := echo("hi").WithEnv("PATH=/no/such/dir").Check(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected exec.ErrNotFound with a PATH that has no echo, got %v", err)
+	}
+	// the command must not be found when it is run either
+	if _, err := echo("hi").WithExtraEnv("PATH=/no/such/dir").Run(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected exec.ErrNotFound running with a PATH that has no echo, got %v", err)
+	}
+
+	dir := filepath.Dir(mustWhich(t, "echo"))
+	if err := echo("hi").WithEnv("PATH=/no/such/dir" + string(filepath.ListSeparator) + dir).Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func mustWhich(t *testing.T, name string) string {
+	path, err := sh.Which(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,81 @@
+package sh
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"labix.org/v2/pipe"
+)
+
+// Xargs returns an Executable that runs the Executable cmd returns for each
+// batch of at most batchSize of args, one batch after another, like xargs -n,
+// to keep each command line under the limit the system puts on it:
+//
+//	rm := func(batch []string) sh.Executable { return sh.Command("rm", sh.Args(batch...)) }
+//	sh.Xargs(rm, files, 1000).Run()
+//
+// The batches are in the order of args, and a batchSize of less than 1 puts
+// all of args in one batch.  With no args, nothing is run.  Each command's
+// stdout and stderr are written as it runs, and its stdin is empty.  Every
+// batch is run even if others fail; the error joins the errors of all that
+// failed, with errors.Join.
+func Xargs(cmd func(batch []string) Executable, args []string, batchSize int) Executable {
+	cmds := batches(cmd, args, batchSize)
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, cmds, func(ctx context.Context, s *pipe.State) error {
+			return runBatches(ctx, s, cmds)
+		})
+	}}
+}
+
+// XargsLines works like Xargs, but takes the args from its stdin, one per
+// line, like xargs -d '\n'.  Empty lines are skipped.  All of stdin is read
+// before the first batch is run.
+func XargsLines(cmd func(batch []string) Executable, batchSize int) Executable {
+	return Executable{func(s *pipe.State) error {
+		return addTaskFor(s, nil, func(ctx context.Context, s *pipe.State) error {
+			var args []string
+			err := forEachLine(ctxReader{ctx, stdin(s)}, func(line string, _ bool) error {
+				if line != "" {
+					args = append(args, line)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return runBatches(ctx, s, batches(cmd, args, batchSize))
+		})
+	}}
+}
+
+// batches returns the Executables that cmd returns for each batch of at most
+// size of args.
+func batches(cmd func(batch []string) Executable, args []string, size int) []Executable {
+	if size < 1 {
+		size = len(args)
+	}
+	var cmds []Executable
+	for len(args) > 0 {
+		n := min(size, len(args))
+		cmds = append(cmds, cmd(args[:n:n]))
+		args = args[n:]
+	}
+	return cmds
+}
+
+// runBatches runs each of cmds in turn with an empty stdin, and joins their
+// errors.
+func runBatches(ctx context.Context, s *pipe.State, cmds []Executable) error {
+	var errs []error
+	for _, c := range cmds {
+		if err := c.runInWith(ctx, s, strings.NewReader(""), s.Stdout, s.Stderr); err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
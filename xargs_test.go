@@ -0,0 +1,51 @@
+package sh_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/natefinch/sh"
+)
+
+func ExampleXargs() {
+	echo := func(batch []string) sh.Executable { return sh.Echo(batch...) }
+
+	fmt.Print(sh.Xargs(echo, []string{"a", "b", "c", "d", "e"}, 2))
+	// output:
+	// a b
+	// c d
+	// e
+}
+
+func ExampleXargsLines() {
+	echo := func(batch []string) sh.Executable { return sh.Command("echo", sh.Args(batch...)) }
+
+	fmt.Print(sh.PipeWith("one\ntwo\n\nthree\n", sh.XargsLines(echo, 0)))
+	// output:
+	// one two three
+}
+
+func TestXargsErrors(t *testing.T) {
+	var batches [][]string
+	cmd := func(batch []string) sh.Executable {
+		batches = append(batches, batch)
+		return sh.Command("test", sh.Args(batch[0], "=", "ok"))
+	}
+	_, err := sh.Xargs(cmd, []string{"ok", "bad1", "ok", "bad2"}, 1).Run()
+	if len(batches) != 4 {
+		t.Errorf("got %d batches, want 4", len(batches))
+	}
+	var exitErr *sh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Args[0] != "bad1" {
+		t.Errorf("got error %v, want the first failure joined in", err)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("got error %v, want 2 errors joined", err)
+	}
+
+	batches = nil
+	if out, err := sh.Xargs(cmd, nil, 10).Run(); err != nil || out != "" || batches != nil {
+		t.Errorf("with no args got %q, %v, %d batches, want nothing run", out, err, len(batches))
+	}
+}